@@ -0,0 +1,186 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+)
+
+//+kubebuilder:object:root=true
+
+// OperatorConfig is the schema of the operator's config file. It extends
+// the standard ControllerManagerConfig with defaults for the Clusters the
+// operator manages
+type OperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ControllerManagerConfigurationSpec holds the manager's own
+	// settings, such as metrics and probe addresses, leader election
+	// and sync period
+	cfg.ControllerManagerConfigurationSpec `json:",inline"`
+
+	// MaxConcurrentReconciles is how many Clusters may be reconciled at
+	// once
+	// +optional
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+
+	// RateLimit tunes how quickly failing Clusters are retried
+	// +optional
+	RateLimit RateLimit `json:"rateLimit,omitempty"`
+
+	// Defaults apply to every Cluster the operator manages
+	// +optional
+	Defaults Defaults `json:"defaults,omitempty"`
+
+	// Tracing configures where reconcile traces are sent
+	// +optional
+	Tracing Tracing `json:"tracing,omitempty"`
+
+	// RegistryTokens allowlists the ECR and GCR tokens Clusters may have
+	// the operator fetch. Unset, Clusters' registry credentials can only
+	// come from Secrets
+	// +optional
+	RegistryTokens RegistryTokens `json:"registryTokens,omitempty"`
+}
+
+// RegistryTokens allowlists the ECR and GCR tokens Clusters may have the
+// operator fetch. Tokens are fetched with the operator's own cloud
+// credentials, and so only for the servers listed
+type RegistryTokens struct {
+	// ECR allows tokens fetched with the operator's AWS credentials
+	// +optional
+	ECR bool `json:"ecr,omitempty"`
+
+	// GCR allows tokens fetched as the operator's GCP service account
+	// +optional
+	GCR bool `json:"gcr,omitempty"`
+
+	// Servers are the registries tokens may be fetched for, such as
+	// 123456789012.dkr.ecr.eu-west-2.amazonaws.com
+	// +optional
+	Servers []string `json:"servers,omitempty"`
+}
+
+// RateLimit tunes how quickly failing Clusters are retried. Each Cluster
+// backs off exponentially between BaseDelay and MaxDelay, while retries
+// across every Cluster are limited to QPS, with bursts of up to Burst.
+//
+// Unset fields take the operator's built-in values
+type RateLimit struct {
+	// +optional
+	BaseDelay metav1.Duration `json:"baseDelay,omitempty"`
+
+	// +optional
+	MaxDelay metav1.Duration `json:"maxDelay,omitempty"`
+
+	// +optional
+	QPS int `json:"qps,omitempty"`
+
+	// +optional
+	Burst int `json:"burst,omitempty"`
+}
+
+// Tracing configures where reconcile traces are sent. Tracing is off
+// unless an exporter is set
+type Tracing struct {
+	// Exporter is one of otlp, which sends traces to an OpenTelemetry
+	// collector over OTLP/HTTP, or stdout
+	// +optional
+	Exporter string `json:"exporter,omitempty"`
+
+	// Endpoint is the base URL of the collector otlp traces are sent
+	// to, such as http://otel-collector:4318. Defaults to
+	// OTEL_EXPORTER_OTLP_ENDPOINT, or http://localhost:4318
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Defaults replace the operator's built-in defaults. Any left unset keep
+// their built-in value
+type Defaults struct {
+	// Resources are the requests and limits of smaller apps, such as the
+	// bot and slacker
+	// +optional
+	Resources corev1.ResourceList `json:"resources,omitempty"`
+
+	// DataResources are the requests and limits of apps which process
+	// data, such as the processor
+	// +optional
+	DataResources corev1.ResourceList `json:"dataResources,omitempty"`
+
+	// Volume configures the bot's database volume
+	// +optional
+	Volume VolumeDefaults `json:"volume,omitempty"`
+
+	// ImageRegistry is the registry, and path within it, app images are
+	// pulled from, such as ghcr.io/gender-equality-community
+	// +optional
+	ImageRegistry string `json:"imageRegistry,omitempty"`
+}
+
+// VolumeDefaults configures the bot's database volume
+type VolumeDefaults struct {
+	// Type is one of pvc or gce
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Size is the size of the volume
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OperatorConfig{})
+}
+
+// Apply returns base with d's defaults set over it. Resources are merged
+// by name, so that a block which sets only cpu keeps base's memory
+func (d Defaults) Apply(base appv1alpha1.Defaults) appv1alpha1.Defaults {
+	base.Resources = mergeResources(base.Resources, d.Resources)
+	base.DataResources = mergeResources(base.DataResources, d.DataResources)
+
+	if d.Volume.Type != "" {
+		base.VolumeType = d.Volume.Type
+	}
+
+	if d.Volume.Size != nil {
+		base.VolumeSize = d.Volume.Size.DeepCopy()
+	}
+
+	if d.ImageRegistry != "" {
+		base.ImageRegistry = d.ImageRegistry
+	}
+
+	return base
+}
+
+func mergeResources(base, override corev1.ResourceList) corev1.ResourceList {
+	merged := base.DeepCopy()
+	if merged == nil {
+		merged = make(corev1.ResourceList)
+	}
+
+	for name, q := range override {
+		merged[name] = q.DeepCopy()
+	}
+
+	return merged
+}
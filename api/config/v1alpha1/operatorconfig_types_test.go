@@ -0,0 +1,110 @@
+package v1alpha1
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestOperatorConfig_Load(t *testing.T) {
+	s := runtime.NewScheme()
+
+	err := AddToScheme(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oc := OperatorConfig{}
+
+	opts, err := ctrl.Options{Scheme: s, MetricsBindAddress: ":9090"}.AndFrom(
+		ctrl.ConfigFile().AtPath(filepath.Join("..", "..", "..", "config", "manager", "controller_manager_config.yaml")).OfKind(&oc),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if opts.MetricsBindAddress != ":9090" {
+		t.Errorf("options should win over the file, received %q", opts.MetricsBindAddress)
+	}
+
+	if opts.HealthProbeBindAddress != ":8081" {
+		t.Errorf("expected probe address from file, received %q", opts.HealthProbeBindAddress)
+	}
+
+	if !opts.LeaderElection || opts.LeaderElectionID != "dfef5bbb.gec" {
+		t.Errorf("expected leader election from file, received %v %q", opts.LeaderElection, opts.LeaderElectionID)
+	}
+
+	if opts.SyncPeriod == nil || *opts.SyncPeriod != 10*time.Hour {
+		t.Errorf("expected sync period from file, received %v", opts.SyncPeriod)
+	}
+
+	if oc.MaxConcurrentReconciles != 4 {
+		t.Errorf("expected 4 concurrent reconciles, received %d", oc.MaxConcurrentReconciles)
+	}
+
+	if oc.RateLimit.MaxDelay.Duration != 5*time.Minute || oc.RateLimit.Burst != 50 {
+		t.Errorf("unexpected rate limit %#v", oc.RateLimit)
+	}
+
+	if oc.Defaults.Volume.Type != "" || oc.Defaults.ImageRegistry != "ghcr.io/gender-equality-community" {
+		t.Errorf("unexpected defaults %#v", oc.Defaults)
+	}
+
+	if oc.RegistryTokens.ECR || oc.RegistryTokens.GCR || len(oc.RegistryTokens.Servers) > 0 {
+		t.Errorf("expected no registry tokens to be allowed, received %#v", oc.RegistryTokens)
+	}
+}
+
+func TestDefaults_Apply(t *testing.T) {
+	size := resource.MustParse("1Gi")
+
+	d := Defaults{
+		DataResources: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("1"),
+		},
+		Volume: VolumeDefaults{
+			Size: &size,
+		},
+		ImageRegistry: "registry.example.com/gec",
+	}.Apply(appv1alpha1.BuiltinDefaults())
+
+	if received := appv1alpha1.ClusterProcessor.Resources(d)[corev1.ResourceCPU]; received.String() != "1" {
+		t.Errorf("expected processor cpu 1, received %s", received.String())
+	}
+
+	if received := appv1alpha1.ClusterProcessor.Resources(d)[corev1.ResourceMemory]; received.String() != "128Mi" {
+		t.Errorf("unset resources should be kept, received processor memory %s", received.String())
+	}
+
+	if received := appv1alpha1.ClusterBot.Resources(d)[corev1.ResourceCPU]; received.String() != "100m" {
+		t.Errorf("unset defaults should be kept, received bot cpu %s", received.String())
+	}
+
+	if d.VolumeType != appv1alpha1.BuiltinDefaults().VolumeType {
+		t.Errorf("unset volume type should be kept, received %q", d.VolumeType)
+	}
+
+	if d.VolumeSize.String() != "1Gi" {
+		t.Errorf("expected volume size 1Gi, received %s", d.VolumeSize.String())
+	}
+
+	cluster := appv1alpha1.Cluster{Spec: appv1alpha1.ClusterSpec{Bot: appv1alpha1.Bot{App: appv1alpha1.App{Version: "v1.0.0"}}}}
+
+	expect := "registry.example.com/gec/gec-bot:v1.0.0"
+	if received := cluster.InClusterImage(d, appv1alpha1.ClusterBot); received != expect {
+		t.Errorf("expected %q, received %q", expect, received)
+	}
+
+	t.Run("builtin defaults are untouched", func(t *testing.T) {
+		if received := appv1alpha1.ClusterProcessor.Resources(appv1alpha1.BuiltinDefaults())[corev1.ResourceCPU]; received.String() != "200m" {
+			t.Errorf("expected processor cpu 200m, received %s", received.String())
+		}
+	})
+}
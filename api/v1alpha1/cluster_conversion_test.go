@@ -0,0 +1,113 @@
+package v1alpha1_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	"github.com/gender-equality-community/gec-operator/api/v1beta1"
+	"github.com/google/go-cmp/cmp"
+	fuzz "github.com/google/gofuzz"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fuzzRounds is how many randomly filled Clusters each round trip is
+// tried with
+const fuzzRounds = 500
+
+func newFuzzer() *fuzz.Fuzzer {
+	return fuzz.New().NilChance(0.2).NumElements(0, 3).Funcs(
+		// RawExtensions hold an interface, which gofuzz can't fill
+		func(e *runtime.RawExtension, c fuzz.Continue) {
+			e.Raw = []byte(`{"spec": {"priorityClassName": "` + c.RandString() + `"}}`)
+		},
+	)
+}
+
+func TestCluster_RoundTripFromHub(t *testing.T) {
+	f := newFuzzer()
+
+	for i := 0; i < fuzzRounds; i++ {
+		src := new(v1alpha1.Cluster)
+		f.Fuzz(src)
+
+		// TypeMeta is set by whichever scheme serializes the result
+		src.TypeMeta = metav1.TypeMeta{}
+
+		spoke := new(v1beta1.Cluster)
+
+		err := spoke.ConvertFrom(src.DeepCopy())
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		received := new(v1alpha1.Cluster)
+
+		err = spoke.ConvertTo(received)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if !equality.Semantic.DeepEqual(src, received) {
+			t.Fatalf("round trip lost data:\n%s", cmp.Diff(src, received))
+		}
+	}
+}
+
+func TestCluster_RoundTripFromSpoke(t *testing.T) {
+	f := newFuzzer()
+
+	for i := 0; i < fuzzRounds; i++ {
+		src := new(v1beta1.Cluster)
+		f.Fuzz(src)
+
+		// TypeMeta is set by whichever scheme serializes the result
+		src.TypeMeta = metav1.TypeMeta{}
+
+		hub := new(v1alpha1.Cluster)
+
+		err := src.DeepCopy().ConvertTo(hub)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		received := new(v1beta1.Cluster)
+
+		err = received.ConvertFrom(hub)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if !equality.Semantic.DeepEqual(src, received) {
+			t.Fatalf("round trip lost data:\n%s", cmp.Diff(src, received))
+		}
+	}
+}
+
+func TestCluster_ConvertRedisURL(t *testing.T) {
+	hub := new(v1alpha1.Cluster)
+
+	err := json.Unmarshal([]byte(`{"spec": {"config": {"redis_url": "redis-master:6379"}}}`), hub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spoke := new(v1beta1.Cluster)
+
+	err = spoke.ConvertFrom(hub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := json.Marshal(spoke.Spec.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := `{"redis":{"url":"redis-master:6379"}}`
+	if string(out) != expect {
+		t.Errorf("expected %s, received %s", expect, out)
+	}
+}
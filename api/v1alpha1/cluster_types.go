@@ -20,7 +20,12 @@ import (
 	"fmt"
 	"strings"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type App struct {
@@ -28,14 +33,180 @@ type App struct {
 	// we prefix 'v' to the version too, since that's what we slap on the front of our git and container tags.
 	// +kubebuilder:validation:Pattern=`^v(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`
 	Version string `json:"version"`
+
+	// Ports are exposed on the app's container and, when set, fronted
+	// by a Service of the same name so that things like metrics and
+	// health endpoints can be reached at a stable address
+	// +optional
+	Ports []corev1.ContainerPort `json:"ports,omitempty"`
+
+	// IngressFrom are let in to the app's ports on top of pods in the
+	// Cluster's namespace, such as a metrics scraper or ingress
+	// controller in namespaces of their own
+	// +optional
+	IngressFrom []networkingv1.NetworkPolicyPeer `json:"ingressFrom,omitempty"`
+
+	// Probes override the app's built-in liveness, readiness and
+	// startup probes. Any probe left unset keeps its default
+	// +optional
+	Probes *Probes `json:"probes,omitempty"`
+
+	// DisruptionBudget configures the PodDisruptionBudget guarding the
+	// app against voluntary evictions, such as node drains
+	// +optional
+	DisruptionBudget *DisruptionBudget `json:"disruptionBudget,omitempty"`
+
+	// Strategy overrides the app's Deployment strategy
+	// +optional
+	Strategy *appsv1.DeploymentStrategy `json:"strategy,omitempty"`
+
+	// Identity replaces the Cluster's cloud identity for this app alone.
+	// Use a provider of none to run an app with no cloud identity at
+	// all. Image pull secrets fall back to the Cluster's where unset
+	// +optional
+	Identity *CloudIdentity `json:"identity,omitempty"`
+
+	// ExtraContainers run alongside the app's own container, such as
+	// log shippers. The operator's security context is enforced on them
+	// +optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	ExtraContainers []corev1.Container `json:"extraContainers,omitempty"`
+
+	// InitContainers run to completion before the app starts, such as
+	// database migrations. The operator's security context is enforced
+	// on them
+	// +optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// ExtraEnv is set on the app's own container, after its generated
+	// config and credentials
+	// +optional
+	ExtraEnv []corev1.EnvVar `json:"extraEnv,omitempty"`
+
+	// ExtraVolumes are added to the app's pods, for use by
+	// extraVolumeMounts and by extra and init containers
+	// +optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	ExtraVolumes []corev1.Volume `json:"extraVolumes,omitempty"`
+
+	// ExtraVolumeMounts are mounted into the app's own container
+	// +optional
+	ExtraVolumeMounts []corev1.VolumeMount `json:"extraVolumeMounts,omitempty"`
+
+	// PodTemplateOverride is a partial PodTemplateSpec, strategic-merged
+	// over the app's generated pod template. It can't weaken the
+	// operator's security settings
+	// +optional
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	PodTemplateOverride *runtime.RawExtension `json:"podTemplateOverride,omitempty"`
+
+	// Suspended scales the app to zero, and keeps it there, while the
+	// rest of the Cluster carries on being reconciled
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// DisruptionBudget mirrors the budget half of a PodDisruptionBudgetSpec.
+// Where both are set, MinAvailable wins
+type DisruptionBudget struct {
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// SecretKeySelector references a single key of a Secret in the same
+// namespace as the Cluster
+type SecretKeySelector struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+}
+
+// CredentialMount determines how a credential is exposed to an app
+// +kubebuilder:validation:Enum=env;file
+type CredentialMount string
+
+const (
+	// CredentialMountEnv exposes a credential as an environment variable
+	CredentialMountEnv CredentialMount = "env"
+
+	// CredentialMountFile exposes a credential as a file under
+	// CredentialsPath, and sets an environment variable suffixed with
+	// _FILE to that file's path
+	CredentialMountFile CredentialMount = "file"
+
+	// CredentialsPath is the directory file credentials are mounted into
+	CredentialsPath = "/credentials/"
+)
+
+// WhatsAppCredentials hold the bot's whatsapp session
+type WhatsAppCredentials struct {
+	Session SecretKeySelector `json:"session"`
+
+	// MountAs defaults to file
+	// +optional
+	MountAs CredentialMount `json:"mountAs,omitempty"`
+}
+
+// SlackCredentials hold the tokens slacker needs to talk to slack
+type SlackCredentials struct {
+	BotToken      SecretKeySelector `json:"botToken"`
+	SigningSecret SecretKeySelector `json:"signingSecret"`
+
+	// MountAs defaults to env
+	// +optional
+	MountAs CredentialMount `json:"mountAs,omitempty"`
+}
+
+// Credential is a single secret value an app requires, along with
+// how that app expects to receive it
+type Credential struct {
+	Ref      SecretKeySelector
+	EnvName  string
+	FileName string
+	MountAs  CredentialMount
+}
+
+// Path returns where a file credential is mounted
+func (c Credential) Path() string {
+	return CredentialsPath + c.FileName
 }
 
+// Probes holds a set of container probes. A probe which sets timings
+// but no handler inherits the handler of the built-in default
+type Probes struct {
+	// +optional
+	Liveness *corev1.Probe `json:"liveness,omitempty"`
+
+	// +optional
+	Readiness *corev1.Probe `json:"readiness,omitempty"`
+
+	// +optional
+	Startup *corev1.Probe `json:"startup,omitempty"`
+}
+
+// The bot holds an exclusive lock on its database, and so is rolled out
+// with the Recreate strategy unless its spec overrides it
 type Bot struct {
 	App `json:",inline"`
+
+	// Credentials references the whatsapp session material the bot
+	// logs in with
+	// +optional
+	Credentials *WhatsAppCredentials `json:"credentials,omitempty"`
 }
 
 func (b Bot) Image() string {
-	return taggedImage(botContainerImage, b.Version)
+	return taggedImage(defaultImageRegistry, botContainerImage, b.Version)
 }
 
 func (b Bot) HasValidSignature() bool {
@@ -52,7 +223,7 @@ type Processor struct {
 }
 
 func (p Processor) Image() string {
-	return taggedImage(processorContainerImage, p.Version)
+	return taggedImage(defaultImageRegistry, processorContainerImage, p.Version)
 }
 
 func (p Processor) HasValidSignature() bool {
@@ -66,10 +237,14 @@ func (p Processor) SBOM() string {
 
 type Slacker struct {
 	App `json:",inline"`
+
+	// Credentials references the slack tokens slacker authenticates with
+	// +optional
+	Credentials *SlackCredentials `json:"credentials,omitempty"`
 }
 
 func (s Slacker) Image() string {
-	return taggedImage(slackerContainerImage, s.Version)
+	return taggedImage(defaultImageRegistry, slackerContainerImage, s.Version)
 }
 
 func (s Slacker) HasValidSignature() bool {
@@ -81,8 +256,63 @@ func (s Slacker) SBOM() string {
 	return sbomURL(slackerContainerImage, s.Version)
 }
 
+// Component is a GEC service beyond the built-in apps, deployed with a
+// ServiceAccount, ConfigMap, Deployment, PodDisruptionBudget, Service and
+// NetworkPolicy of its own
+type Component struct {
+	// Name is used in object names and as the app label, and must not
+	// clash with a built-in app
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=40
+	Name string `json:"name"`
+
+	App `json:",inline"`
+
+	// Image is the repository to pull, tagged with version. Images
+	// which name no registry are pulled from the operator's default
+	// registry
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Config is passed to the component as environment variables,
+	// alongside REDIS_ADDR
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+
+	// Resources are the component's requests and limits, which
+	// otherwise come from the operator's defaults
+	// +optional
+	Resources corev1.ResourceList `json:"resources,omitempty"`
+
+	// Database gives the component a persistent volume, mounted at
+	// /database/, as the bot has
+	// +optional
+	Database bool `json:"database,omitempty"`
+}
+
+// Config holds settings shared by every app in a Cluster.
+//
+// Exactly one of RedisURL or RedisService should be set, and which one
+// can't change once a Cluster is created
+// +kubebuilder:validation:XValidation:rule="has(self.redis_url) != has(self.redisService)",message="exactly one of redis_url or redisService must be set"
+// +kubebuilder:validation:XValidation:rule="has(self.redisService) == has(oldSelf.redisService)",message="redis can't be switched between redis_url and redisService"
 type Config struct {
-	RedisURL string `json:"redis_url"`
+	// RedisURL is the address of the redis instance apps share
+	// +kubebuilder:validation:Pattern=`^(rediss?://([^@/\s]+@)?)?[A-Za-z0-9]([-A-Za-z0-9.]*[A-Za-z0-9])?(:[0-9]{1,5})?(/[0-9]+)?$`
+	// +optional
+	RedisURL string `json:"redis_url,omitempty"`
+
+	// RedisService has redis found through its Service, which the
+	// operator resolves to an address, and follows should it change.
+	// It's immutable; recreate the Cluster to move redis elsewhere
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="redisService is immutable"
+	// +optional
+	RedisService *RedisServiceRef `json:"redisService,omitempty"`
+
+	// RedisPasswordSecret is copied into the Cluster's namespace, and
+	// passed to every app as REDIS_PASSWORD
+	// +optional
+	RedisPasswordSecret *RedisSecretRef `json:"redisPasswordSecret,omitempty"`
 }
 
 // ClusterSpec defines the desired state of Cluster
@@ -91,16 +321,176 @@ type ClusterSpec struct {
 	Processor Processor `json:"processor"`
 	Slacker   Slacker   `json:"slacker"`
 	Config    Config    `json:"config"`
+
+	// Identity configures the cloud identity apps run as
+	// +optional
+	Identity *CloudIdentity `json:"identity,omitempty"`
+
+	// Registry configures an image pull secret which the operator
+	// creates, keeps fresh, and attaches to every app's ServiceAccount
+	// +optional
+	Registry *RegistryCredentials `json:"registry,omitempty"`
+
+	// Components are deployed alongside the built-in apps, and are
+	// reconciled after them, in order
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Components []Component `json:"components,omitempty"`
+
+	// MaintenanceWindows limit when disruptive changes, such as those
+	// which roll an app's pods, are applied. Without any, changes are
+	// applied straight away
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// Paused stops the operator reconciling the Cluster at all, so that
+	// hand-made changes to its objects stand until it's unpaused
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+// AppStatus is the observed state of a single app's Deployment
+type AppStatus struct {
+	// Version is the version currently rolled out
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Replicas is the number of pods the Deployment wants
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// ReadyReplicas is the number of pods passing their readiness probe
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Ready is true when every desired pod is up to date and passing
+	// its readiness probe
+	Ready bool `json:"ready"`
 }
 
+// ClusterPhase summarises the state of a Cluster's apps
+// +kubebuilder:validation:Enum=Pending;Progressing;Ready;Paused
+type ClusterPhase string
+
+const (
+	// ClusterPending means at least one app has yet to be deployed
+	ClusterPending ClusterPhase = "Pending"
+
+	// ClusterProgressing means every app is deployed, but not every app
+	// is ready
+	ClusterProgressing ClusterPhase = "Progressing"
+
+	// ClusterReady means every app is ready
+	ClusterReady ClusterPhase = "Ready"
+
+	// ClusterPaused means the operator has stopped reconciling the
+	// Cluster, and so whatever state its apps are in is left alone
+	ClusterPaused ClusterPhase = "Paused"
+)
+
 // ClusterStatus defines the observed state of Cluster
 type ClusterStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// +optional
+	Phase ClusterPhase `json:"phase,omitempty"`
+
+	// +optional
+	Bot AppStatus `json:"bot,omitempty"`
+
+	// +optional
+	Processor AppStatus `json:"processor,omitempty"`
+
+	// +optional
+	Slacker AppStatus `json:"slacker,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Components []ComponentStatus `json:"components,omitempty"`
+
+	// PendingChanges are disruptive changes held back until the next
+	// maintenance window
+	// +optional
+	PendingChanges []PendingChange `json:"pendingChanges,omitempty"`
+
+	// NextMaintenanceWindow is when PendingChanges will be applied
+	// +optional
+	NextMaintenanceWindow *metav1.Time `json:"nextMaintenanceWindow,omitempty"`
+
+	// Suspended lists the apps which are suspended
+	// +optional
+	Suspended []string `json:"suspended,omitempty"`
+
+	// Plan is written instead of anything being changed, while the
+	// Cluster is being planned
+	// +optional
+	Plan *Plan `json:"plan,omitempty"`
+	// RedisAddress is the address redis' Service was last resolved to
+	// +optional
+	RedisAddress string `json:"redisAddress,omitempty"`
+}
+
+// PendingChange is a change to an object which the operator is holding
+// back until the next maintenance window
+type PendingChange struct {
+	// App the object belongs to
+	App string `json:"app"`
+
+	// Kind of the object
+	Kind string `json:"kind"`
+
+	// Name of the object
+	Name string `json:"name"`
+}
+
+// ComponentStatus is the observed state of a component from a Cluster's
+// spec
+type ComponentStatus struct {
+	Name string `json:"name"`
+
+	AppStatus `json:",inline"`
+}
+
+// App returns a pointer to the status for a ClusterApp, or nil where
+// that ClusterApp has no status of its own. Components get an entry the
+// first time they're asked for
+func (s *ClusterStatus) App(ca ClusterApp) *AppStatus {
+	switch ca {
+	case ClusterBot:
+		return &s.Bot
+	case ClusterProcessor:
+		return &s.Processor
+	case ClusterSlacker:
+		return &s.Slacker
+	}
+
+	if !ca.Generic() {
+		return nil
+	}
+
+	name := ca.String()
+	for i := range s.Components {
+		if s.Components[i].Name == name {
+			return &s.Components[i].AppStatus
+		}
+	}
+
+	s.Components = append(s.Components, ComponentStatus{Name: name})
+
+	return &s.Components[len(s.Components)-1].AppStatus
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Bot",type=string,JSONPath=`.status.bot.version`
+//+kubebuilder:printcolumn:name="Processor",type=string,JSONPath=`.status.processor.version`
+//+kubebuilder:printcolumn:name="Slacker",type=string,JSONPath=`.status.slacker.version`
+//+kubebuilder:printcolumn:name="Suspended",type=string,JSONPath=`.status.suspended`
+//+kubebuilder:printcolumn:name="Bot Ready",type=boolean,JSONPath=`.status.bot.ready`,priority=1
+//+kubebuilder:printcolumn:name="Processor Ready",type=boolean,JSONPath=`.status.processor.ready`,priority=1
+//+kubebuilder:printcolumn:name="Slacker Ready",type=boolean,JSONPath=`.status.slacker.ready`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Cluster is the Schema for the clusters API
 type Cluster struct {
@@ -115,22 +505,135 @@ func (c Cluster) InClusterName(ca ClusterApp) string {
 	return fmt.Sprintf("%s-%s", c.ObjectMeta.Name, ca.String())
 }
 
-func (c Cluster) InClusterImage(ca ClusterApp) string {
+func (c Cluster) InClusterApp(ca ClusterApp) App {
 	switch ca {
 	case ClusterBot:
-		return c.Spec.Bot.Image()
-
+		return c.Spec.Bot.App
 	case ClusterProcessor:
-		return c.Spec.Processor.Image()
+		return c.Spec.Processor.App
+	case ClusterSlacker:
+		return c.Spec.Slacker.App
+	}
+
+	if comp := c.component(ca); comp != nil {
+		return comp.App
+	}
+
+	return App{}
+}
+
+// InClusterProbes returns the probes for a ClusterApp; the built-in
+// defaults with any overrides from the spec applied on top
+func (c Cluster) InClusterProbes(ca ClusterApp) (p Probes, err error) {
+	a := c.InClusterApp(ca)
+	def := ca.Probes(a.Ports)
+
+	if a.Probes == nil {
+		return def, nil
+	}
+
+	p.Liveness, err = mergeProbe(def.Liveness, a.Probes.Liveness)
+	if err != nil {
+		return p, fmt.Errorf("%s liveness: %w", ca, err)
+	}
+
+	p.Readiness, err = mergeProbe(def.Readiness, a.Probes.Readiness)
+	if err != nil {
+		return p, fmt.Errorf("%s readiness: %w", ca, err)
+	}
+
+	p.Startup, err = mergeProbe(def.Startup, a.Probes.Startup)
+	if err != nil {
+		return p, fmt.Errorf("%s startup: %w", ca, err)
+	}
+
+	return p, nil
+}
+
+// InClusterDisruptionBudget returns the disruption budget for a
+// ClusterApp, falling back to allowing a single pod to be disrupted at a
+// time
+func (c Cluster) InClusterDisruptionBudget(ca ClusterApp) DisruptionBudget {
+	db := c.InClusterApp(ca).DisruptionBudget
+	if db == nil || (db.MinAvailable == nil && db.MaxUnavailable == nil) {
+		maxUnavailable := intstr.FromInt(1)
+
+		return DisruptionBudget{MaxUnavailable: &maxUnavailable}
+	}
+
+	if db.MinAvailable != nil {
+		return DisruptionBudget{MinAvailable: db.MinAvailable}
+	}
+
+	return DisruptionBudget{MaxUnavailable: db.MaxUnavailable}
+}
+
+// InClusterStrategy returns the Deployment strategy for a ClusterApp;
+// either the override from the spec, or the ClusterApp's default
+func (c Cluster) InClusterStrategy(ca ClusterApp) appsv1.DeploymentStrategy {
+	s := c.InClusterApp(ca).Strategy
+	if s == nil {
+		return ca.Strategy()
+	}
+
+	return defaultStrategy(*s.DeepCopy())
+}
+
+// InClusterCredentials returns the credentials a ClusterApp requires,
+// or nil where none are configured
+func (c Cluster) InClusterCredentials(ca ClusterApp) []Credential {
+	switch ca {
+	case ClusterBot:
+		wc := c.Spec.Bot.Credentials
+		if wc == nil {
+			return nil
+		}
+
+		return []Credential{
+			{Ref: wc.Session, EnvName: "WHATSAPP_SESSION", FileName: "whatsapp-session", MountAs: mountOrDefault(wc.MountAs, CredentialMountFile)},
+		}
 
 	case ClusterSlacker:
-		return c.Spec.Slacker.Image()
+		sc := c.Spec.Slacker.Credentials
+		if sc == nil {
+			return nil
+		}
+
+		mount := mountOrDefault(sc.MountAs, CredentialMountEnv)
+
+		return []Credential{
+			{Ref: sc.BotToken, EnvName: "SLACK_TOKEN", FileName: "slack-token", MountAs: mount},
+			{Ref: sc.SigningSecret, EnvName: "SLACK_SIGNING_SECRET", FileName: "slack-signing-secret", MountAs: mount},
+		}
 
 	default:
-		return ""
+		return nil
 	}
 }
 
+func mountOrDefault(m, def CredentialMount) CredentialMount {
+	if m == "" {
+		return def
+	}
+
+	return m
+}
+
+// InClusterImage returns the image a ClusterApp runs; either its
+// component's own image, or the one in its definition, pulled from d's
+// ImageRegistry
+func (c Cluster) InClusterImage(d Defaults, ca ClusterApp) string {
+	if comp := c.component(ca); comp != nil {
+		return imageRef(d.ImageRegistry, comp.Image, comp.Version)
+	}
+
+	if image := ca.Definition().Image; image != "" {
+		return imageRef(d.ImageRegistry, image, c.InClusterApp(ca).Version)
+	}
+
+	return ""
+}
+
 //+kubebuilder:object:root=true
 
 // ClusterList contains a list of Cluster
@@ -144,8 +647,8 @@ func init() {
 	SchemeBuilder.Register(&Cluster{}, &ClusterList{})
 }
 
-func taggedImage(image, tag string) string {
-	return fmt.Sprintf("%s:%s", image, tag)
+func taggedImage(registry, image, tag string) string {
+	return fmt.Sprintf("%s/%s:%s", strings.TrimSuffix(registry, "/"), image, tag)
 }
 
 func sbomURL(image, tag string) string {
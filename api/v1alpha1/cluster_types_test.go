@@ -3,7 +3,10 @@ package v1alpha1
 import (
 	"testing"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 var (
@@ -53,7 +56,7 @@ func TestCluster_InClusterImage(t *testing.T) {
 		{UnknownClusterApp, ""},
 	} {
 		t.Run(test.ca.String(), func(t *testing.T) {
-			received := cluster.InClusterImage(test.ca)
+			received := cluster.InClusterImage(BuiltinDefaults(), test.ca)
 
 			if test.expect != received {
 				t.Errorf("expected %q, received %q", test.expect, received)
@@ -95,3 +98,138 @@ func TestSBOM(t *testing.T) {
 		})
 	}
 }
+
+func TestCluster_InClusterApp(t *testing.T) {
+	for _, test := range []struct {
+		ca     ClusterApp
+		expect string
+	}{
+		{ClusterBot, "v0.1.0"},
+		{ClusterProcessor, "v0.1.0"},
+		{ClusterSlacker, "v0.1.0"},
+		{UnknownClusterApp, ""},
+	} {
+		t.Run(test.ca.String(), func(t *testing.T) {
+			received := cluster.InClusterApp(test.ca).Version
+			if test.expect != received {
+				t.Errorf("expected %q, received %q", test.expect, received)
+			}
+		})
+	}
+}
+
+func TestCluster_InClusterProbes(t *testing.T) {
+	c := cluster.DeepCopy()
+	c.Spec.Bot.Ports = []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}
+	c.Spec.Bot.Probes = &Probes{
+		Liveness: &corev1.Probe{PeriodSeconds: 60},
+		Readiness: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				Exec: &corev1.ExecAction{Command: []string{"/healthcheck"}},
+			},
+		},
+	}
+
+	received, err := c.InClusterProbes(ClusterBot)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	t.Run("liveness inherits handler", func(t *testing.T) {
+		if received.Liveness.HTTPGet == nil {
+			t.Errorf("expected http handler, received %#v", received.Liveness.ProbeHandler)
+		}
+
+		if received.Liveness.PeriodSeconds != 60 {
+			t.Errorf("expected 60, received %d", received.Liveness.PeriodSeconds)
+		}
+	})
+
+	t.Run("readiness overrides handler", func(t *testing.T) {
+		if received.Readiness.Exec == nil || received.Readiness.HTTPGet != nil {
+			t.Errorf("expected exec handler, received %#v", received.Readiness.ProbeHandler)
+		}
+
+		if received.Readiness.TimeoutSeconds != 1 {
+			t.Errorf("expected defaulted timeout, received %d", received.Readiness.TimeoutSeconds)
+		}
+	})
+
+	t.Run("startup keeps default", func(t *testing.T) {
+		if received.Startup.FailureThreshold != 30 {
+			t.Errorf("expected 30, received %d", received.Startup.FailureThreshold)
+		}
+	})
+}
+
+func TestCluster_InClusterDisruptionBudget(t *testing.T) {
+	minAvailable := intstr.FromInt(1)
+	maxUnavailable := intstr.FromString("50%")
+
+	for _, test := range []struct {
+		name                 string
+		db                   *DisruptionBudget
+		expectMinAvailable   string
+		expectMaxUnavailable string
+	}{
+		{"default", nil, "", "1"},
+		{"empty", &DisruptionBudget{}, "", "1"},
+		{"min available", &DisruptionBudget{MinAvailable: &minAvailable}, "1", ""},
+		{"max unavailable", &DisruptionBudget{MaxUnavailable: &maxUnavailable}, "", "50%"},
+		{"both", &DisruptionBudget{MinAvailable: &minAvailable, MaxUnavailable: &maxUnavailable}, "1", ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := cluster.DeepCopy()
+			c.Spec.Bot.DisruptionBudget = test.db
+
+			received := c.InClusterDisruptionBudget(ClusterBot)
+
+			if test.expectMinAvailable != stringOrEmpty(received.MinAvailable) {
+				t.Errorf("expected %q, received %q", test.expectMinAvailable, stringOrEmpty(received.MinAvailable))
+			}
+
+			if test.expectMaxUnavailable != stringOrEmpty(received.MaxUnavailable) {
+				t.Errorf("expected %q, received %q", test.expectMaxUnavailable, stringOrEmpty(received.MaxUnavailable))
+			}
+		})
+	}
+}
+
+func stringOrEmpty(i *intstr.IntOrString) string {
+	if i == nil {
+		return ""
+	}
+
+	return i.String()
+}
+
+func TestCluster_InClusterStrategy(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		ca       ClusterApp
+		strategy *appsv1.DeploymentStrategy
+		expect   appsv1.DeploymentStrategyType
+	}{
+		{"bot default", ClusterBot, nil, appsv1.RecreateDeploymentStrategyType},
+		{"processor default", ClusterProcessor, nil, appsv1.RollingUpdateDeploymentStrategyType},
+		{"bot override", ClusterBot, &appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType}, appsv1.RollingUpdateDeploymentStrategyType},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := cluster.DeepCopy()
+			c.Spec.Bot.Strategy = test.strategy
+
+			received := c.InClusterStrategy(test.ca)
+			if test.expect != received.Type {
+				t.Errorf("expected %q, received %q", test.expect, received.Type)
+			}
+
+			if received.Type == appsv1.RollingUpdateDeploymentStrategyType && received.RollingUpdate == nil {
+				t.Error("expected rolling update parameters to be defaulted")
+			}
+
+			if received.Type == appsv1.RecreateDeploymentStrategyType && received.RollingUpdate != nil {
+				t.Error("unexpected rolling update parameters")
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ComponentDefinition describes how the operator builds a ClusterApp.
+//
+// The built-in apps have definitions of their own; components listed in a
+// Cluster's spec get the defaults below
+type ComponentDefinition struct {
+	// Name is used in object names and as the app label
+	Name string
+
+	// Image is the repository of the component's image, without a tag.
+	// Components from a Cluster's spec set their own image, and so leave
+	// this empty
+	Image string
+
+	// DataResources gives the component DataResources, rather than
+	// DefaultResources
+	DataResources bool
+
+	// Resources are the component's own requests and limits, which
+	// take the place of either of the above where set
+	Resources corev1.ResourceList
+
+	// StartupFailureThreshold is how many failed startup probes the
+	// component is allowed. Zero means 6
+	StartupFailureThreshold int32
+
+	// Recreate stops old pods before new ones are started
+	Recreate bool
+
+	// Database mounts a persistent volume into the component at
+	// /database/
+	Database bool
+
+	generic bool
+}
+
+// reservedComponentNames can't be used by components in a Cluster's
+// spec, on top of the names of built-in apps
+var reservedComponentNames = map[string]bool{
+	"metadata": true,
+	"registry": true,
+}
+
+// builtinDefinitions are the definitions of the apps built into the
+// operator. They're never written to, and so are safe to share between
+// reconciles
+var builtinDefinitions = map[ClusterApp]ComponentDefinition{
+	UnknownClusterApp: {Name: "unknown"},
+	ClusterBot:        {Name: "gec-bot", Image: botContainerImage, StartupFailureThreshold: 30, Recreate: true, Database: true},
+	ClusterProcessor:  {Name: "gec-processor", Image: processorContainerImage, DataResources: true, StartupFailureThreshold: 18},
+	ClusterSlacker:    {Name: "gec-slacker", Image: slackerContainerImage},
+	ClusterMeta:       {Name: "meta"},
+}
+
+// Definition returns the definition of a ClusterApp; a built-in app's
+// own, or the defaults every component from a Cluster's spec gets
+func (c ClusterApp) Definition() ComponentDefinition {
+	if d, ok := builtinDefinitions[c]; ok {
+		return d
+	}
+
+	if c == "" || reservedComponentNames[string(c)] {
+		return builtinDefinitions[UnknownClusterApp]
+	}
+
+	return ComponentDefinition{Name: string(c), generic: true}
+}
+
+// InClusterDefinition returns the definition of a ClusterApp, with the
+// resources and database volume its component sets where it's one from
+// the Cluster's spec
+func (c Cluster) InClusterDefinition(ca ClusterApp) ComponentDefinition {
+	d := ca.Definition()
+
+	if comp := c.component(ca); comp != nil {
+		d.Resources = comp.Resources
+		d.Database = comp.Database
+	}
+
+	return d
+}
+
+// InClusterResources returns a ClusterApp's requests and limits, from
+// its component where set, otherwise from d
+func (c Cluster) InClusterResources(d Defaults, ca ClusterApp) corev1.ResourceList {
+	return c.InClusterDefinition(ca).resources(d)
+}
+
+// InClusterVolumeMount returns where a ClusterApp's database volume is
+// mounted, if it has one
+func (c Cluster) InClusterVolumeMount(ca ClusterApp) []corev1.VolumeMount {
+	return c.InClusterDefinition(ca).volumeMount(c.InClusterName(ca))
+}
+
+// InClusterVolume returns a ClusterApp's database volume, of d's
+// VolumeType, if it has one
+func (c Cluster) InClusterVolume(d Defaults, ca ClusterApp) []corev1.Volume {
+	return c.InClusterDefinition(ca).volume(d, c.InClusterName(ca))
+}
+
+// Generic is true for components from a Cluster's spec, rather than
+// those built into the operator
+func (c ClusterApp) Generic() bool {
+	return c.Definition().generic
+}
+
+// Apps returns every ClusterApp a Cluster runs; the built-in apps, then
+// each component in its spec
+func (c Cluster) Apps() (apps []ClusterApp, err error) {
+	apps = []ClusterApp{ClusterBot, ClusterProcessor, ClusterSlacker}
+
+	for _, comp := range c.Spec.Components {
+		ca := ClusterApp(comp.Name)
+		if !ca.Generic() {
+			return apps, fmt.Errorf("component name %q is reserved", comp.Name)
+		}
+
+		apps = append(apps, ca)
+	}
+
+	return
+}
+
+// component returns the spec of a component from a Cluster's spec, or
+// nil where ca isn't one of them
+func (c Cluster) component(ca ClusterApp) *Component {
+	if !ca.Generic() {
+		return nil
+	}
+
+	name := ca.String()
+	for i := range c.Spec.Components {
+		if c.Spec.Components[i].Name == name {
+			return &c.Spec.Components[i]
+		}
+	}
+
+	return nil
+}
+
+// imageRef tags an image, pulling it from registry where the image names
+// no registry of its own.
+//
+// As with docker, an image names a registry where its first path segment
+// looks like a host: it holds a '.' or ':', or is localhost. Otherwise,
+// such as with gender-equality-community/gec-bot, it's a path within
+// registry
+func imageRef(registry, image, tag string) string {
+	if host, _, ok := strings.Cut(image, "/"); ok && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		return fmt.Sprintf("%s:%s", image, tag)
+	}
+
+	return taggedImage(registry, image, tag)
+}
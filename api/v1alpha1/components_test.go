@@ -0,0 +1,196 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestClusterApp_Definition(t *testing.T) {
+	for _, test := range []struct {
+		ca            ClusterApp
+		expectName    string
+		expectGeneric bool
+	}{
+		{ClusterBot, "gec-bot", false},
+		{ClusterMeta, "meta", false},
+		{UnknownClusterApp, "unknown", false},
+		{ClusterApp(""), "unknown", false},
+		{ClusterApp("metadata"), "unknown", false},
+		{ClusterApp("gec-translator"), "gec-translator", true},
+	} {
+		t.Run(test.expectName, func(t *testing.T) {
+			d := test.ca.Definition()
+			if test.expectName != d.Name {
+				t.Errorf("expected %q, received %q", test.expectName, d.Name)
+			}
+
+			if test.expectGeneric != test.ca.Generic() {
+				t.Errorf("expected generic %v", test.expectGeneric)
+			}
+		})
+	}
+}
+
+func TestCluster_Apps(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		components  []Component
+		expectNames []string
+		expectError bool
+	}{
+		{"built-in apps only", nil, []string{"gec-bot", "gec-processor", "gec-slacker"}, false},
+		{"with components", []Component{{Name: "gec-translator"}, {Name: "gec-archiver"}}, []string{"gec-bot", "gec-processor", "gec-slacker", "gec-translator", "gec-archiver"}, false},
+		{"built-in name", []Component{{Name: "gec-slacker"}}, nil, true},
+		{"reserved name", []Component{{Name: "metadata"}}, nil, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := cluster
+			c.Spec.Components = test.components
+
+			apps, err := c.Apps()
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if len(test.expectNames) != len(apps) {
+				t.Fatalf("expected %v, received %v", test.expectNames, apps)
+			}
+
+			for i, ca := range apps {
+				if test.expectNames[i] != ca.String() {
+					t.Errorf("expected %q, received %q", test.expectNames[i], ca.String())
+				}
+			}
+
+			// Components keep their ClusterApp from one call to the next
+			again, _ := c.Apps()
+			for i := range apps {
+				if apps[i] != again[i] {
+					t.Errorf("expected %q, received %q", apps[i], again[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCluster_Apps_Many(t *testing.T) {
+	// Components are resolved per Cluster, so there's no limit to how
+	// many different names may be seen over the operator's lifetime
+	for i := 0; i < 1000; i++ {
+		c := cluster
+		c.Spec.Components = []Component{{Name: fmt.Sprintf("gec-component-%d", i)}}
+
+		apps, err := c.Apps()
+		if err != nil {
+			t.Fatalf("component %d: unexpected error: %#v", i, err)
+		}
+
+		if received := apps[len(apps)-1].String(); received != c.Spec.Components[0].Name {
+			t.Fatalf("expected %q, received %q", c.Spec.Components[0].Name, received)
+		}
+	}
+}
+
+func TestCluster_Components(t *testing.T) {
+	c := cluster
+	c.Spec.Components = []Component{
+		{Name: "gec-translator", App: App{Version: "v0.2.0"}, Image: "gec-translator"},
+		{Name: "gec-archiver", App: App{Version: "v0.3.0"}, Image: "registry.example.com/archiver"},
+		{Name: "gec-indexer", App: App{Version: "v0.4.0"}, Image: "search/indexer", Database: true, Resources: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("500m"),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		}},
+	}
+
+	apps, err := c.Apps()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	translator, archiver, indexer := apps[3], apps[4], apps[5]
+
+	for _, test := range []struct {
+		ca            ClusterApp
+		expectImage   string
+		expectVersion string
+		expectCPU     string
+		expectVolume  bool
+	}{
+		{translator, "ghcr.io/gender-equality-community/gec-translator:v0.2.0", "v0.2.0", "100m", false},
+		{archiver, "registry.example.com/archiver:v0.3.0", "v0.3.0", "100m", false},
+		{indexer, "ghcr.io/gender-equality-community/search/indexer:v0.4.0", "v0.4.0", "500m", true},
+	} {
+		t.Run(test.ca.String(), func(t *testing.T) {
+			if received := c.InClusterImage(BuiltinDefaults(), test.ca); test.expectImage != received {
+				t.Errorf("expected %q, received %q", test.expectImage, received)
+			}
+
+			if received := c.InClusterApp(test.ca).Version; test.expectVersion != received {
+				t.Errorf("expected %q, received %q", test.expectVersion, received)
+			}
+
+			resources := c.InClusterResources(BuiltinDefaults(), test.ca)
+			if received := resources.Cpu().String(); test.expectCPU != received {
+				t.Errorf("expected cpu %q, received %q", test.expectCPU, received)
+			}
+
+			if received := len(c.InClusterVolume(BuiltinDefaults(), test.ca)) > 0; test.expectVolume != received {
+				t.Errorf("expected volume %v, received %v", test.expectVolume, received)
+			}
+
+			if received := len(c.InClusterVolumeMount(test.ca)) > 0; test.expectVolume != received {
+				t.Errorf("expected volume mount %v, received %v", test.expectVolume, received)
+			}
+		})
+	}
+
+	t.Run("status", func(t *testing.T) {
+		s := new(ClusterStatus)
+
+		s.App(archiver).Ready = true
+		s.App(translator).Version = "v0.2.0"
+
+		if !s.App(archiver).Ready || s.App(translator).Version != "v0.2.0" {
+			t.Errorf("unexpected status %#v", s)
+		}
+
+		if len(s.Components) != 2 || s.Components[0].Name != "gec-archiver" {
+			t.Errorf("unexpected components %#v", s.Components)
+		}
+
+		if s.App(ClusterMeta) != nil {
+			t.Errorf("expected meta to have no status")
+		}
+	})
+}
+
+func TestImageRef(t *testing.T) {
+	for _, test := range []struct {
+		image  string
+		expect string
+	}{
+		{"gec-translator", "ghcr.io/gender-equality-community/gec-translator:v1.0.0"},
+		{"search/indexer", "ghcr.io/gender-equality-community/search/indexer:v1.0.0"},
+		{"registry.example.com/archiver", "registry.example.com/archiver:v1.0.0"},
+		{"registry:5000/archiver", "registry:5000/archiver:v1.0.0"},
+		{"localhost/archiver", "localhost/archiver:v1.0.0"},
+	} {
+		t.Run(test.image, func(t *testing.T) {
+			received := imageRef(defaultImageRegistry, test.image, "v1.0.0")
+			if test.expect != received {
+				t.Errorf("expected %q, received %q", test.expect, received)
+			}
+		})
+	}
+}
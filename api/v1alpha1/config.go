@@ -1,20 +1,26 @@
 package v1alpha1
 
 import (
+	"errors"
 	"os"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-type ClusterApp uint8
+// ClusterApp identifies one of a Cluster's apps by name. The built-in
+// apps are below; components from a Cluster's spec are known by their own
+// names, which Cluster.Apps checks don't clash with them
+type ClusterApp string
 
 const (
-	UnknownClusterApp ClusterApp = iota
-	ClusterBot
-	ClusterProcessor
-	ClusterSlacker
-	ClusterMeta
+	UnknownClusterApp ClusterApp = "unknown"
+	ClusterBot        ClusterApp = "gec-bot"
+	ClusterProcessor  ClusterApp = "gec-processor"
+	ClusterSlacker    ClusterApp = "gec-slacker"
+	ClusterMeta       ClusterApp = "meta"
 )
 
 const (
@@ -23,66 +29,249 @@ MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEfh98reV6VTLXq2TzyekyybK1sPiD
 7ndQ+oC6kjGsQawwMUCFU7oCpW2hmjXA/Zj4x6A4zPZl/3nvRTVDsIMxHA==
 -----END PUBLIC KEY-----
 `
-	botContainerImage       = "ghcr.io/gender-equality-community/gec-bot"
-	processorContainerImage = "ghcr.io/gender-equality-community/gec-processor"
-	slackerContainerImage   = "ghcr.io/gender-equality-community/gec-slacker"
+	botContainerImage       = "gec-bot"
+	processorContainerImage = "gec-processor"
+	slackerContainerImage   = "gec-slacker"
+
+	healthPath = "/healthz"
 )
 
-var (
+// defaultImageRegistry is where app images are pulled from, unless the
+// operator's config file says otherwise
+const defaultImageRegistry = "ghcr.io/gender-equality-community"
+
+// Defaults are the operator-wide defaults apps are built with. The
+// operator's config file overrides them; otherwise they take the values
+// BuiltinDefaults returns
+type Defaults struct {
+	// Resources are used for smaller containers, largely written in go
+	Resources corev1.ResourceList
+
+	// DataResources are used for containers which perform data-y
+	// tasks, such as taggers and labelers
+	DataResources corev1.ResourceList
+
 	// VolumeType is used to determine things like storage classes and
 	// volume configs between cluster types.
 	//
 	// For instance, on GCP we want to use GCE disks, whereas locally we
 	// might actually want our NFS volumes
-	VolumeType = os.Getenv("VOLUME_TYPE")
-	VolumeSize = resource.MustParse("100Mi")
+	VolumeType string
+	VolumeSize resource.Quantity
 
-	// Default resources are used for smaller containers, largely
-	// written in go
-	defaultCpu = resource.MustParse("100m")
-	defaultMem = resource.MustParse("64Mi")
+	// ImageRegistry is the registry, and path within it, that app
+	// images are pulled from
+	ImageRegistry string
+}
 
-	// Data resources are used for containers which perform data-y
-	// tasks, such as taggers and labelers
-	dataCpu = resource.MustParse("200m")
-	dataMem = resource.MustParse("128Mi")
-)
+// BuiltinDefaults returns the operator's own defaults. VolumeType comes
+// from the VOLUME_TYPE environment variable
+func BuiltinDefaults() Defaults {
+	return Defaults{
+		Resources: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("64Mi"),
+		},
+		DataResources: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("200m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+		VolumeType:    os.Getenv("VOLUME_TYPE"),
+		VolumeSize:    resource.MustParse("100Mi"),
+		ImageRegistry: defaultImageRegistry,
+	}
+}
 
 func (c ClusterApp) String() string {
-	switch c {
-	case ClusterBot:
-		return "gec-bot"
+	return c.Definition().Name
+}
 
-	case ClusterProcessor:
-		return "gec-processor"
+// Resources returns a ClusterApp's requests and limits from d
+func (c ClusterApp) Resources(d Defaults) corev1.ResourceList {
+	return c.Definition().resources(d)
+}
 
-	case ClusterSlacker:
-		return "gec-slacker"
+func (cd ComponentDefinition) resources(d Defaults) corev1.ResourceList {
+	switch {
+	case len(cd.Resources) > 0:
+		return cd.Resources.DeepCopy()
 
-	case ClusterMeta:
-		return "meta"
+	case cd.DataResources:
+		return d.DataResources.DeepCopy()
 
 	default:
-		return "unknown"
+		return d.Resources.DeepCopy()
+	}
+}
+
+// Probes returns the built-in probes for a ClusterApp.
+//
+// Probes target the port named 'health' or 'http' over HTTP where an
+// app exposes one, otherwise the first exposed port over TCP. Apps which
+// expose no ports get no built-in probes at all; none of the built-in
+// images serve a health endpoint of their own yet.
+//
+// The bot restores its whatsapp session and the processor loads its
+// models on boot, so both get a longer startup allowance than slacker
+func (c ClusterApp) Probes(ports []corev1.ContainerPort) Probes {
+	handler, ok := probeHandler(ports)
+	if !ok {
+		return Probes{}
+	}
+
+	startupThreshold := c.Definition().StartupFailureThreshold
+	if startupThreshold == 0 {
+		startupThreshold = 6
+	}
+
+	return Probes{
+		Liveness: DefaultProbe(&corev1.Probe{
+			ProbeHandler:     handler,
+			PeriodSeconds:    20,
+			FailureThreshold: 3,
+		}),
+		Readiness: DefaultProbe(&corev1.Probe{
+			ProbeHandler:     handler,
+			PeriodSeconds:    10,
+			FailureThreshold: 3,
+		}),
+		Startup: DefaultProbe(&corev1.Probe{
+			ProbeHandler:     handler,
+			PeriodSeconds:    10,
+			FailureThreshold: startupThreshold,
+		}),
+	}
+}
+
+func probeHandler(ports []corev1.ContainerPort) (h corev1.ProbeHandler, ok bool) {
+	if len(ports) == 0 {
+		return
+	}
+
+	for _, p := range ports {
+		if p.Name == "health" || p.Name == "http" {
+			return httpHandler(p.ContainerPort), true
+		}
+	}
+
+	return corev1.ProbeHandler{
+		TCPSocket: &corev1.TCPSocketAction{
+			Port: intstr.FromInt(int(ports[0].ContainerPort)),
+		},
+	}, true
+}
+
+func httpHandler(port int32) corev1.ProbeHandler {
+	return corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path: healthPath,
+			Port: intstr.FromInt(int(port)),
+		},
+	}
+}
+
+// errNoProbeHandler is returned for an overriding probe which sets no
+// handler, where there's no built-in probe to inherit one from
+var errNoProbeHandler = errors.New("probe sets no handler, and the app has no built-in probe to inherit one from")
+
+// mergeProbe overlays an overriding probe on top of a default, inheriting
+// the default's handler where the override sets none
+func mergeProbe(def, override *corev1.Probe) (*corev1.Probe, error) {
+	if override == nil {
+		return def, nil
+	}
+
+	p := override.DeepCopy()
+	if p.Exec == nil && p.HTTPGet == nil && p.TCPSocket == nil && p.GRPC == nil {
+		if def == nil {
+			return nil, errNoProbeHandler
+		}
+
+		p.ProbeHandler = *def.ProbeHandler.DeepCopy()
+	}
+
+	return DefaultProbe(p), nil
+}
+
+// DefaultProbe fills in the fields the API server would otherwise default,
+// so that comparisons against the live Deployment hold
+func DefaultProbe(p *corev1.Probe) *corev1.Probe {
+	if p == nil {
+		return nil
+	}
+
+	if p.TimeoutSeconds == 0 {
+		p.TimeoutSeconds = 1
+	}
+
+	if p.PeriodSeconds == 0 {
+		p.PeriodSeconds = 10
+	}
+
+	if p.SuccessThreshold == 0 {
+		p.SuccessThreshold = 1
+	}
+
+	if p.FailureThreshold == 0 {
+		p.FailureThreshold = 3
 	}
+
+	if p.HTTPGet != nil && p.HTTPGet.Scheme == "" {
+		p.HTTPGet.Scheme = corev1.URISchemeHTTP
+	}
+
+	return p
 }
 
-func (c ClusterApp) Resources() corev1.ResourceList {
-	if c == ClusterProcessor {
-		return corev1.ResourceList{
-			corev1.ResourceCPU:    dataCpu,
-			corev1.ResourceMemory: dataMem,
+// Strategy returns the default Deployment strategy for a ClusterApp.
+//
+// The bot holds an exclusive lock on its database for the lifetime of its
+// whatsapp session; two bots must never run against the same volume, so
+// the old pod is always stopped before a new one starts
+func (c ClusterApp) Strategy() appsv1.DeploymentStrategy {
+	if c.Definition().Recreate {
+		return appsv1.DeploymentStrategy{
+			Type: appsv1.RecreateDeploymentStrategyType,
 		}
 	}
 
-	return corev1.ResourceList{
-		corev1.ResourceCPU:    defaultCpu,
-		corev1.ResourceMemory: defaultMem,
+	return defaultStrategy(appsv1.DeploymentStrategy{})
+}
+
+// defaultStrategy fills in the fields the API server would otherwise default,
+// so that comparisons against the live Deployment hold
+func defaultStrategy(s appsv1.DeploymentStrategy) appsv1.DeploymentStrategy {
+	if s.Type == "" {
+		s.Type = appsv1.RollingUpdateDeploymentStrategyType
+	}
+
+	if s.Type != appsv1.RollingUpdateDeploymentStrategyType {
+		return s
+	}
+
+	if s.RollingUpdate == nil {
+		s.RollingUpdate = new(appsv1.RollingUpdateDeployment)
 	}
+
+	if s.RollingUpdate.MaxUnavailable == nil {
+		maxUnavailable := intstr.FromString("25%")
+		s.RollingUpdate.MaxUnavailable = &maxUnavailable
+	}
+
+	if s.RollingUpdate.MaxSurge == nil {
+		maxSurge := intstr.FromString("25%")
+		s.RollingUpdate.MaxSurge = &maxSurge
+	}
+
+	return s
 }
 
 func (c ClusterApp) VolumeMount(name string) []corev1.VolumeMount {
-	if c != ClusterBot {
+	return c.Definition().volumeMount(name)
+}
+
+func (cd ComponentDefinition) volumeMount(name string) []corev1.VolumeMount {
+	if !cd.Database {
 		return nil
 	}
 
@@ -94,12 +283,17 @@ func (c ClusterApp) VolumeMount(name string) []corev1.VolumeMount {
 	}
 }
 
-func (c ClusterApp) Volume(name string) []corev1.Volume {
-	if c != ClusterBot {
+// Volume returns a ClusterApp's database volume, of d's VolumeType
+func (c ClusterApp) Volume(d Defaults, name string) []corev1.Volume {
+	return c.Definition().volume(d, name)
+}
+
+func (cd ComponentDefinition) volume(d Defaults, name string) []corev1.Volume {
+	if !cd.Database {
 		return nil
 	}
 
-	switch VolumeType {
+	switch d.VolumeType {
 	case "gce":
 		return gceVolume(name)
 
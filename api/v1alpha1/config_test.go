@@ -1,7 +1,10 @@
 package v1alpha1
 
 import (
+	"reflect"
 	"testing"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 func TestClusterApp_String(t *testing.T) {
@@ -14,7 +17,7 @@ func TestClusterApp_String(t *testing.T) {
 		{ClusterSlacker, "gec-slacker"},
 		{ClusterMeta, "meta"},
 		{UnknownClusterApp, "unknown"},
-		{ClusterApp(240), "unknown"},
+		{ClusterApp(""), "unknown"},
 	} {
 		t.Run(test.expect, func(t *testing.T) {
 			received := test.ca.String()
@@ -35,7 +38,7 @@ func TestClusterApp_Resources(t *testing.T) {
 		{ClusterProcessor, "200m", "128Mi"},
 	} {
 		t.Run(test.ca.String(), func(t *testing.T) {
-			received := test.ca.Resources()
+			received := test.ca.Resources(BuiltinDefaults())
 
 			t.Run("CPU", func(t *testing.T) {
 				if test.expectCPU != received.Cpu().String() {
@@ -88,10 +91,83 @@ func TestClusterApp_Volume(t *testing.T) {
 		{ClusterProcessor, 0},
 	} {
 		t.Run(test.ca.String(), func(t *testing.T) {
-			received := len(test.ca.Volume("foo"))
+			received := len(test.ca.Volume(BuiltinDefaults(), "foo"))
 			if test.expectLen != received {
 				t.Errorf("expected %d volume(s), received %d", test.expectLen, received)
 			}
 		})
 	}
 }
+
+func TestClusterApp_Probes(t *testing.T) {
+	for _, test := range []struct {
+		name             string
+		ca               ClusterApp
+		ports            []corev1.ContainerPort
+		expectNil        bool
+		expectHTTP       bool
+		expectStartupMax int32
+	}{
+		{"no ports", ClusterBot, nil, true, false, 0},
+		{"no ports on a component", ClusterApp("gec-tagger"), nil, true, false, 0},
+		{"health port", ClusterBot, []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9000}, {Name: "health", ContainerPort: 8080}}, false, true, 30},
+		{"tcp fallback", ClusterProcessor, []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9000}}, false, false, 18},
+		{"slacker", ClusterSlacker, []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}, false, true, 6},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := test.ca.Probes(test.ports)
+
+			if test.expectNil {
+				if received.Liveness != nil || received.Readiness != nil || received.Startup != nil {
+					t.Errorf("expected no probes, received %#v", received)
+				}
+
+				return
+			}
+
+			if test.expectHTTP != (received.Readiness.HTTPGet != nil) {
+				t.Errorf("expected http probe %v, received %#v", test.expectHTTP, received.Readiness.ProbeHandler)
+			}
+
+			if test.expectStartupMax != received.Startup.FailureThreshold {
+				t.Errorf("expected %d, received %d", test.expectStartupMax, received.Startup.FailureThreshold)
+			}
+		})
+	}
+}
+
+func TestMergeProbe(t *testing.T) {
+	def := DefaultProbe(&corev1.Probe{ProbeHandler: httpHandler(8080)})
+	exec := corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"/healthcheck"}}}
+
+	for _, test := range []struct {
+		name          string
+		def           *corev1.Probe
+		override      *corev1.Probe
+		expectHandler corev1.ProbeHandler
+		expectErr     bool
+	}{
+		{"no override", def, nil, def.ProbeHandler, false},
+		{"inherited handler", def, &corev1.Probe{PeriodSeconds: 60}, def.ProbeHandler, false},
+		{"own handler", def, &corev1.Probe{ProbeHandler: exec}, exec, false},
+		{"own handler without default", nil, &corev1.Probe{ProbeHandler: exec}, exec, false},
+		{"no handler at all", nil, &corev1.Probe{PeriodSeconds: 60}, corev1.ProbeHandler{}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received, err := mergeProbe(test.def, test.override)
+			if err == nil && test.expectErr {
+				t.Fatalf("expected error")
+			} else if err != nil && !test.expectErr {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if test.expectErr {
+				return
+			}
+
+			if !reflect.DeepEqual(test.expectHandler, received.ProbeHandler) {
+				t.Errorf("expected %#v, received %#v", test.expectHandler, received.ProbeHandler)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// IdentityProvider selects how apps authenticate against their cloud
+// provider
+// +kubebuilder:validation:Enum=none;gke;eks;azure
+type IdentityProvider string
+
+const (
+	IdentityProviderNone  IdentityProvider = "none"
+	IdentityProviderGKE   IdentityProvider = "gke"
+	IdentityProviderEKS   IdentityProvider = "eks"
+	IdentityProviderAzure IdentityProvider = "azure"
+)
+
+// CloudIdentity configures the workload identity apps run as, along
+// with the image pull secrets their ServiceAccounts carry
+// +kubebuilder:validation:XValidation:rule="!has(self.provider) || self.provider == 'none' || (self.provider == 'gke' && has(self.gke)) || (self.provider == 'eks' && has(self.eks)) || (self.provider == 'azure' && has(self.azure))",message="provider requires its own settings, such as gke for provider gke"
+type CloudIdentity struct {
+	// Provider defaults to none
+	// +optional
+	Provider IdentityProvider `json:"provider,omitempty"`
+
+	// +optional
+	GKE *GKEIdentity `json:"gke,omitempty"`
+
+	// +optional
+	EKS *EKSIdentity `json:"eks,omitempty"`
+
+	// +optional
+	Azure *AzureIdentity `json:"azure,omitempty"`
+
+	// ImagePullSecrets are attached to every app's ServiceAccount
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// GKEIdentity binds apps to a GCP service account via GKE Workload Identity
+type GKEIdentity struct {
+	Project string `json:"project"`
+
+	// ServiceAccount is the name of the GCP service account, without
+	// the project suffix. Defaults to the name of the Cluster
+	// +optional
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+}
+
+// EKSIdentity binds apps to an IAM role via IRSA
+type EKSIdentity struct {
+	RoleARN string `json:"roleArn"`
+}
+
+// AzureIdentity binds apps to a managed identity or app registration via
+// Azure Workload Identity
+type AzureIdentity struct {
+	ClientID string `json:"clientId"`
+
+	// +optional
+	TenantID string `json:"tenantId,omitempty"`
+}
+
+// IdentityAnnotations are every annotation ServiceAccountAnnotations may
+// set, whichever the provider. The operator owns these on the
+// ServiceAccounts it creates, and leaves any others alone
+var IdentityAnnotations = []string{
+	"iam.gke.io/gcp-service-account",
+	"eks.amazonaws.com/role-arn",
+	"azure.workload.identity/client-id",
+	"azure.workload.identity/tenant-id",
+}
+
+// ServiceAccountAnnotations returns the annotations a ServiceAccount
+// needs in order to assume this identity
+func (i CloudIdentity) ServiceAccountAnnotations(c Cluster) map[string]string {
+	switch i.Provider {
+	case IdentityProviderGKE:
+		if i.GKE == nil {
+			return nil
+		}
+
+		name := i.GKE.ServiceAccount
+		if name == "" {
+			name = c.Name
+		}
+
+		return map[string]string{
+			"iam.gke.io/gcp-service-account": fmt.Sprintf("%s@%s.iam.gserviceaccount.com", name, i.GKE.Project),
+		}
+
+	case IdentityProviderEKS:
+		if i.EKS == nil {
+			return nil
+		}
+
+		return map[string]string{
+			"eks.amazonaws.com/role-arn": i.EKS.RoleARN,
+		}
+
+	case IdentityProviderAzure:
+		if i.Azure == nil {
+			return nil
+		}
+
+		a := map[string]string{
+			"azure.workload.identity/client-id": i.Azure.ClientID,
+		}
+
+		if i.Azure.TenantID != "" {
+			a["azure.workload.identity/tenant-id"] = i.Azure.TenantID
+		}
+
+		return a
+
+	default:
+		return nil
+	}
+}
+
+// IdentityPodLabels are every label PodLabels may set, whichever the
+// provider. The operator owns these on the pod templates it creates
+var IdentityPodLabels = []string{
+	"azure.workload.identity/use",
+}
+
+// PodLabels returns any labels pods need in order to assume this identity
+func (i CloudIdentity) PodLabels() map[string]string {
+	if i.Provider == IdentityProviderAzure && i.Azure != nil {
+		return map[string]string{
+			"azure.workload.identity/use": "true",
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCloudIdentity_ServiceAccountAnnotations(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		i      CloudIdentity
+		expect map[string]string
+	}{
+		{"none", CloudIdentity{Provider: IdentityProviderNone}, nil},
+		{"unset", CloudIdentity{}, nil},
+		{"gke", CloudIdentity{Provider: IdentityProviderGKE, GKE: &GKEIdentity{Project: "my-project"}}, map[string]string{"iam.gke.io/gcp-service-account": "testing@my-project.iam.gserviceaccount.com"}},
+		{"gke with service account", CloudIdentity{Provider: IdentityProviderGKE, GKE: &GKEIdentity{Project: "my-project", ServiceAccount: "gec"}}, map[string]string{"iam.gke.io/gcp-service-account": "gec@my-project.iam.gserviceaccount.com"}},
+		{"gke missing config", CloudIdentity{Provider: IdentityProviderGKE}, nil},
+		{"eks", CloudIdentity{Provider: IdentityProviderEKS, EKS: &EKSIdentity{RoleARN: "arn:aws:iam::123456789012:role/gec"}}, map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/gec"}},
+		{"azure", CloudIdentity{Provider: IdentityProviderAzure, Azure: &AzureIdentity{ClientID: "abc"}}, map[string]string{"azure.workload.identity/client-id": "abc"}},
+		{"azure with tenant", CloudIdentity{Provider: IdentityProviderAzure, Azure: &AzureIdentity{ClientID: "abc", TenantID: "def"}}, map[string]string{"azure.workload.identity/client-id": "abc", "azure.workload.identity/tenant-id": "def"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := test.i.ServiceAccountAnnotations(cluster)
+			if !reflect.DeepEqual(test.expect, received) {
+				t.Errorf("expected %#v, received %#v", test.expect, received)
+			}
+		})
+	}
+}
+
+func TestCloudIdentity_PodLabels(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		i      CloudIdentity
+		expect map[string]string
+	}{
+		{"gke", CloudIdentity{Provider: IdentityProviderGKE, GKE: &GKEIdentity{Project: "my-project"}}, nil},
+		{"azure", CloudIdentity{Provider: IdentityProviderAzure, Azure: &AzureIdentity{ClientID: "abc"}}, map[string]string{"azure.workload.identity/use": "true"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := test.i.PodLabels()
+			if !reflect.DeepEqual(test.expect, received) {
+				t.Errorf("expected %#v, received %#v", test.expect, received)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApplyNowAnnotation, set on a Cluster to any value, applies changes held
+// back for a maintenance window straight away. The operator removes it
+// once they are applied
+const ApplyNowAnnotation = "app.gec/apply-now"
+
+// Weekday names a day of the week
+// +kubebuilder:validation:Enum=Monday;Tuesday;Wednesday;Thursday;Friday;Saturday;Sunday
+type Weekday string
+
+// MaintenanceWindow is a recurring period during which the operator may
+// apply disruptive changes, such as rolling pods or resizing volumes
+type MaintenanceWindow struct {
+	// Days the window opens on. Empty means every day
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+
+	// Start is the time of day the window opens, as HH:MM
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// Duration is how long the window stays open, up to a week
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone is the IANA name of the time zone Start is in. Defaults
+	// to UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// maxWindowDuration keeps the search for open windows bounded
+const maxWindowDuration = 7 * 24 * time.Hour
+
+// Open returns whether the window is open at t, and otherwise when it
+// next opens
+func (w MaintenanceWindow) Open(t time.Time) (open bool, next time.Time, err error) {
+	loc := time.UTC
+	if w.TimeZone != "" {
+		loc, err = time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return
+		}
+	}
+
+	var hour, minute int
+
+	_, err = fmt.Sscanf(w.Start, "%d:%d", &hour, &minute)
+	if err != nil {
+		return false, next, fmt.Errorf("invalid start %q: %w", w.Start, err)
+	}
+
+	d := w.Duration.Duration
+	if d <= 0 || d > maxWindowDuration {
+		return false, next, fmt.Errorf("invalid duration %s", d)
+	}
+
+	// Windows open at most a week before t can still be open, and one
+	// opens at most a week after t
+	local := t.In(loc)
+	for offset := -8; offset <= 8; offset++ {
+		day := local.AddDate(0, 0, offset)
+		start := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+
+		if !w.onDay(start.Weekday()) {
+			continue
+		}
+
+		if !t.Before(start) && t.Before(start.Add(d)) {
+			return true, time.Time{}, nil
+		}
+
+		if start.After(t) && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+
+	return false, next, nil
+}
+
+func (w MaintenanceWindow) onDay(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, day := range w.Days {
+		if string(day) == d.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InMaintenanceWindow returns whether disruptive changes may be applied
+// to a Cluster at t, and otherwise when they next may be. Clusters
+// without maintenance windows, or with ApplyNowAnnotation set, may be
+// changed at any time
+func (c Cluster) InMaintenanceWindow(t time.Time) (open bool, next time.Time, err error) {
+	if len(c.Spec.MaintenanceWindows) == 0 {
+		return true, time.Time{}, nil
+	}
+
+	if _, ok := c.Annotations[ApplyNowAnnotation]; ok {
+		return true, time.Time{}, nil
+	}
+
+	for _, w := range c.Spec.MaintenanceWindows {
+		wOpen, wNext, err := w.Open(t)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+
+		if wOpen {
+			return true, time.Time{}, nil
+		}
+
+		if next.IsZero() || wNext.Before(next) {
+			next = wNext
+		}
+	}
+
+	return false, next, nil
+}
@@ -0,0 +1,93 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMaintenanceWindow_Open(t *testing.T) {
+	// A Monday
+	at := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	hours := func(h int) metav1.Duration {
+		return metav1.Duration{Duration: time.Duration(h) * time.Hour}
+	}
+
+	for _, test := range []struct {
+		name        string
+		window      MaintenanceWindow
+		expectOpen  bool
+		expectNext  time.Time
+		expectError bool
+	}{
+		{"every day, open", MaintenanceWindow{Start: "11:00", Duration: hours(2)}, true, time.Time{}, false},
+		{"every day, later today", MaintenanceWindow{Start: "22:00", Duration: hours(2)}, false, time.Date(2022, 8, 1, 22, 0, 0, 0, time.UTC), false},
+		{"every day, closes as it starts", MaintenanceWindow{Start: "10:00", Duration: hours(2)}, false, time.Date(2022, 8, 2, 10, 0, 0, 0, time.UTC), false},
+		{"opened yesterday, still open", MaintenanceWindow{Days: []Weekday{"Sunday"}, Start: "23:00", Duration: hours(14)}, true, time.Time{}, false},
+		{"weekends only", MaintenanceWindow{Days: []Weekday{"Saturday", "Sunday"}, Start: "02:00", Duration: hours(4)}, false, time.Date(2022, 8, 6, 2, 0, 0, 0, time.UTC), false},
+		{"today, next week", MaintenanceWindow{Days: []Weekday{"Monday"}, Start: "09:00", Duration: hours(1)}, false, time.Date(2022, 8, 8, 9, 0, 0, 0, time.UTC), false},
+		{"time zone, open", MaintenanceWindow{Start: "12:30", Duration: hours(1), TimeZone: "Europe/London"}, true, time.Time{}, false},
+		{"time zone, next", MaintenanceWindow{Start: "09:00", Duration: hours(1), TimeZone: "America/New_York"}, false, time.Date(2022, 8, 1, 13, 0, 0, 0, time.UTC), false},
+		{"bad time zone", MaintenanceWindow{Start: "09:00", Duration: hours(1), TimeZone: "Nowhere/Special"}, false, time.Time{}, true},
+		{"bad start", MaintenanceWindow{Start: "noon", Duration: hours(1)}, false, time.Time{}, true},
+		{"no duration", MaintenanceWindow{Start: "09:00"}, false, time.Time{}, true},
+		{"over a week", MaintenanceWindow{Start: "09:00", Duration: hours(24 * 8)}, false, time.Time{}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			open, next, err := test.window.Open(at)
+			if err == nil && test.expectError {
+				t.Fatalf("expected error")
+			} else if err != nil && !test.expectError {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if test.expectOpen != open {
+				t.Errorf("expected open %v, received %v", test.expectOpen, open)
+			}
+
+			if !test.expectNext.Equal(next) {
+				t.Errorf("expected next %s, received %s", test.expectNext, next)
+			}
+		})
+	}
+}
+
+func TestCluster_InMaintenanceWindow(t *testing.T) {
+	at := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	windows := []MaintenanceWindow{
+		{Start: "22:00", Duration: metav1.Duration{Duration: time.Hour}},
+		{Start: "20:00", Duration: metav1.Duration{Duration: time.Hour}},
+	}
+
+	for _, test := range []struct {
+		name       string
+		cluster    Cluster
+		expectOpen bool
+		expectNext time.Time
+	}{
+		{"no windows", Cluster{}, true, time.Time{}},
+		{"soonest window", Cluster{Spec: ClusterSpec{MaintenanceWindows: windows}}, false, time.Date(2022, 8, 1, 20, 0, 0, 0, time.UTC)},
+		{"applied now", Cluster{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{ApplyNowAnnotation: ""}},
+			Spec:       ClusterSpec{MaintenanceWindows: windows},
+		}, true, time.Time{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			open, next, err := test.cluster.InMaintenanceWindow(at)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if test.expectOpen != open {
+				t.Errorf("expected open %v, received %v", test.expectOpen, open)
+			}
+
+			if !test.expectNext.Equal(next) {
+				t.Errorf("expected next %s, received %s", test.expectNext, next)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package v1alpha1
+
+// PlanAnnotation, set on a Cluster to any value, has the operator work
+// out what it would change without changing anything, and write that into
+// the Cluster's status.plan
+const PlanAnnotation = "app.gec/plan"
+
+// PlanAction is what the operator would do to an object
+// +kubebuilder:validation:Enum=Create;Update;Delete;None
+type PlanAction string
+
+const (
+	PlanCreate PlanAction = "Create"
+	PlanUpdate PlanAction = "Update"
+	PlanDelete PlanAction = "Delete"
+	PlanNone   PlanAction = "None"
+)
+
+// PlannedChange is what the operator would do to a single object
+type PlannedChange struct {
+	// App the object belongs to
+	App string `json:"app"`
+
+	// Kind of the object
+	Kind string `json:"kind"`
+
+	// Name of the object
+	Name string `json:"name"`
+
+	Action PlanAction `json:"action"`
+}
+
+// Plan lists what the operator would do to each of a Cluster's objects,
+// were it not planning
+type Plan struct {
+	// ObservedGeneration is the generation of the spec which was planned
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// +optional
+	Changes []PlannedChange `json:"changes,omitempty"`
+}
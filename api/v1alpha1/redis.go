@@ -0,0 +1,86 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RedisServiceRef locates redis by the Service it's served by, which may
+// be in another namespace, such as one holding shared infrastructure
+type RedisServiceRef struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Namespace defaults to the Cluster's own
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Port is the name of the Service port redis listens on. Defaults to
+	// the Service's first port
+	// +optional
+	Port string `json:"port,omitempty"`
+}
+
+// RedisSecretRef references a Secret holding redis' password, which may
+// be in another namespace
+type RedisSecretRef struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Namespace defaults to the Cluster's own
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Key defaults to 'password'
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// PasswordKey returns the key the password is read from, defaulting where
+// unset
+func (r RedisSecretRef) PasswordKey() string {
+	if r.Key == "" {
+		return "password"
+	}
+
+	return r.Key
+}
+
+// RedisService returns where the Service a Cluster's redis is served by
+// is, if it references one
+func (c Cluster) RedisService() (nn types.NamespacedName, ok bool) {
+	ref := c.Spec.Config.RedisService
+	if ref == nil {
+		return
+	}
+
+	return types.NamespacedName{Name: ref.Name, Namespace: namespaceOr(ref.Namespace, c.Namespace)}, true
+}
+
+// RedisPasswordSecret returns where the Secret holding a Cluster's redis
+// password is, if it references one
+func (c Cluster) RedisPasswordSecret() (nn types.NamespacedName, ok bool) {
+	ref := c.Spec.Config.RedisPasswordSecret
+	if ref == nil {
+		return
+	}
+
+	return types.NamespacedName{Name: ref.Name, Namespace: namespaceOr(ref.Namespace, c.Namespace)}, true
+}
+
+// RedisAddr returns the address apps reach redis at; the address its
+// Service was last resolved to, or otherwise RedisURL
+func (c Cluster) RedisAddr() string {
+	if c.Spec.Config.RedisService != nil {
+		return c.Status.RedisAddress
+	}
+
+	return c.Spec.Config.RedisURL
+}
+
+func namespaceOr(ns, def string) string {
+	if ns == "" {
+		return def
+	}
+
+	return ns
+}
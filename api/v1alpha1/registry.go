@@ -0,0 +1,65 @@
+package v1alpha1
+
+// RegistryCredentials configure the image pull secret the operator
+// creates for a Cluster and attaches to each app's ServiceAccount.
+//
+// Exactly one of SecretRef, ECR, or GCR should be set
+// +kubebuilder:validation:XValidation:rule="[has(self.secretRef), has(self.ecr), has(self.gcr)].filter(x, x).size() == 1",message="exactly one of secretRef, ecr or gcr must be set"
+type RegistryCredentials struct {
+	// Server is the registry the credentials are for, such as ghcr.io or
+	// 123456789012.dkr.ecr.eu-west-2.amazonaws.com
+	Server string `json:"server"`
+
+	// SecretRef reads a static username and password from a Secret
+	// +optional
+	SecretRef *RegistrySecretRef `json:"secretRef,omitempty"`
+
+	// ECR fetches short-lived tokens from AWS ECR using the operator's own
+	// AWS credentials, where the operator's config allows them for Server
+	// +optional
+	ECR *ECRTokenSource `json:"ecr,omitempty"`
+
+	// GCR fetches short-lived tokens for GCR or Artifact Registry from the
+	// GCP metadata server, as the operator's own service account, where
+	// the operator's config allows them for Server
+	// +optional
+	GCR *GCRTokenSource `json:"gcr,omitempty"`
+}
+
+// RegistrySecretRef references a Secret, in the same namespace as the
+// Cluster, holding registry credentials
+type RegistrySecretRef struct {
+	Name string `json:"name"`
+
+	// UsernameKey defaults to 'username'
+	// +optional
+	UsernameKey string `json:"usernameKey,omitempty"`
+
+	// PasswordKey defaults to 'password'
+	// +optional
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+// ECRTokenSource configures fetching ECR authorization tokens
+type ECRTokenSource struct {
+	Region string `json:"region"`
+}
+
+// GCRTokenSource configures fetching GCP access tokens
+type GCRTokenSource struct{}
+
+// Keys returns the username and password keys to read, defaulting
+// where unset
+func (r RegistrySecretRef) Keys() (username, password string) {
+	username, password = r.UsernameKey, r.PasswordKey
+
+	if username == "" {
+		username = "username"
+	}
+
+	if password == "" {
+		password = "password"
+	}
+
+	return
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this Cluster to the hub version, v1alpha1
+func (src *Cluster) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.Cluster)
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = v1alpha1.ClusterSpec{
+		Bot:       src.Spec.Bot,
+		Processor: src.Spec.Processor,
+		Slacker:   src.Spec.Slacker,
+		Config: v1alpha1.Config{
+			RedisURL:            src.Spec.Config.Redis.URL,
+			RedisService:        src.Spec.Config.Redis.Service,
+			RedisPasswordSecret: src.Spec.Config.Redis.PasswordSecret,
+		},
+		Identity:           src.Spec.Identity,
+		Registry:           src.Spec.Registry,
+		Components:         src.Spec.Components,
+		MaintenanceWindows: src.Spec.MaintenanceWindows,
+		Paused:             src.Spec.Paused,
+	}
+	dst.Status = src.Status
+
+	return nil
+}
+
+// ConvertFrom converts from the hub version, v1alpha1, to this Cluster
+func (dst *Cluster) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.Cluster)
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = ClusterSpec{
+		Bot:       src.Spec.Bot,
+		Processor: src.Spec.Processor,
+		Slacker:   src.Spec.Slacker,
+		Config: Config{
+			Redis: RedisConfig{
+				URL:            src.Spec.Config.RedisURL,
+				Service:        src.Spec.Config.RedisService,
+				PasswordSecret: src.Spec.Config.RedisPasswordSecret,
+			},
+		},
+		Identity:           src.Spec.Identity,
+		Registry:           src.Spec.Registry,
+		Components:         src.Spec.Components,
+		MaintenanceWindows: src.Spec.MaintenanceWindows,
+		Paused:             src.Spec.Paused,
+	}
+	dst.Status = src.Status
+
+	return nil
+}
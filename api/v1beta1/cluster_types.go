@@ -0,0 +1,123 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RedisConfig configures the redis instance a Cluster's apps share.
+//
+// Exactly one of URL or Service should be set, and which one can't
+// change once a Cluster is created
+// +kubebuilder:validation:XValidation:rule="has(self.url) != has(self.service)",message="exactly one of url or service must be set"
+// +kubebuilder:validation:XValidation:rule="has(self.service) == has(oldSelf.service)",message="redis can't be switched between url and service"
+type RedisConfig struct {
+	// URL is the address of redis, either as host:port or as a
+	// redis:// URL
+	// +kubebuilder:validation:Pattern=`^(rediss?://([^@/\s]+@)?)?[A-Za-z0-9]([-A-Za-z0-9.]*[A-Za-z0-9])?(:[0-9]{1,5})?(/[0-9]+)?$`
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Service has redis found through its Service, which the operator
+	// resolves to an address, and follows should it change. It's
+	// immutable; recreate the Cluster to move redis elsewhere
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="service is immutable"
+	// +optional
+	Service *v1alpha1.RedisServiceRef `json:"service,omitempty"`
+
+	// PasswordSecret is copied into the Cluster's namespace, and passed
+	// to every app as REDIS_PASSWORD
+	// +optional
+	PasswordSecret *v1alpha1.RedisSecretRef `json:"passwordSecret,omitempty"`
+}
+
+// Config holds settings shared by every app in a Cluster
+type Config struct {
+	Redis RedisConfig `json:"redis"`
+}
+
+// ClusterSpec defines the desired state of Cluster
+type ClusterSpec struct {
+	Bot       v1alpha1.Bot       `json:"bot"`
+	Processor v1alpha1.Processor `json:"processor"`
+	Slacker   v1alpha1.Slacker   `json:"slacker"`
+	Config    Config             `json:"config"`
+
+	// Identity configures the cloud identity apps run as
+	// +optional
+	Identity *v1alpha1.CloudIdentity `json:"identity,omitempty"`
+
+	// Registry configures an image pull secret which the operator
+	// creates, keeps fresh, and attaches to every app's ServiceAccount
+	// +optional
+	Registry *v1alpha1.RegistryCredentials `json:"registry,omitempty"`
+
+	// Components are deployed alongside the built-in apps, and are
+	// reconciled after them, in order
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Components []v1alpha1.Component `json:"components,omitempty"`
+
+	// MaintenanceWindows limit when disruptive changes, such as those
+	// which roll an app's pods, are applied. Without any, changes are
+	// applied straight away
+	// +optional
+	MaintenanceWindows []v1alpha1.MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// Paused stops the operator reconciling the Cluster at all, so that
+	// hand-made changes to its objects stand until it's unpaused
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Bot",type=string,JSONPath=`.status.bot.version`
+//+kubebuilder:printcolumn:name="Processor",type=string,JSONPath=`.status.processor.version`
+//+kubebuilder:printcolumn:name="Slacker",type=string,JSONPath=`.status.slacker.version`
+//+kubebuilder:printcolumn:name="Suspended",type=string,JSONPath=`.status.suspended`
+//+kubebuilder:printcolumn:name="Bot Ready",type=boolean,JSONPath=`.status.bot.ready`,priority=1
+//+kubebuilder:printcolumn:name="Processor Ready",type=boolean,JSONPath=`.status.processor.ready`,priority=1
+//+kubebuilder:printcolumn:name="Slacker Ready",type=boolean,JSONPath=`.status.slacker.ready`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Cluster is the Schema for the clusters API
+type Cluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterSpec            `json:"spec,omitempty"`
+	Status v1alpha1.ClusterStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterList contains a list of Cluster
+type ClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Cluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Cluster{}, &ClusterList{})
+}
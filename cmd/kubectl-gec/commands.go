@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	"github.com/gender-equality-community/gec-operator/controllers"
+)
+
+// plugin holds what each command needs to talk to the cluster
+type plugin struct {
+	client    client.Client
+	clientset kubernetes.Interface
+	namespace string
+	out       io.Writer
+	http      *http.Client
+}
+
+// run dispatches a command, checking it was given the right arguments
+func (p *plugin) run(ctx context.Context, command string, args []string) error {
+	nargs := map[string]int{
+		"status":  1,
+		"upgrade": 3,
+		"pause":   1,
+		"resume":  1,
+		"sbom":    2,
+		"logs":    2,
+	}
+
+	n, ok := nargs[command]
+	if !ok {
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	var follow bool
+
+	if command == "logs" {
+		flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+		flags.BoolVar(&follow, "f", false, "Stream logs as they're written.")
+		flags.BoolVar(&follow, "follow", false, "Stream logs as they're written.")
+
+		err := flags.Parse(args)
+		if err != nil {
+			return err
+		}
+
+		args = flags.Args()
+	}
+
+	if len(args) != n {
+		return fmt.Errorf("%s takes %d arguments, received %d", command, n, len(args))
+	}
+
+	switch command {
+	case "status":
+		return p.status(ctx, args[0])
+	case "upgrade":
+		return p.upgrade(ctx, args[0], args[1], args[2])
+	case "pause":
+		return p.setPaused(ctx, args[0], true)
+	case "resume":
+		return p.setPaused(ctx, args[0], false)
+	case "sbom":
+		return p.sbom(ctx, args[0], args[1])
+	default:
+		return p.logs(ctx, args[0], args[1], follow)
+	}
+}
+
+func (p *plugin) cluster(ctx context.Context, name string) (*appv1alpha1.Cluster, error) {
+	app := new(appv1alpha1.Cluster)
+
+	err := p.client.Get(ctx, types.NamespacedName{Name: name, Namespace: p.namespace}, app)
+
+	return app, err
+}
+
+// lookupApp finds a Cluster's app by name, with or without its gec-
+// prefix
+func lookupApp(app *appv1alpha1.Cluster, name string) (appv1alpha1.ClusterApp, error) {
+	apps, err := app.Apps()
+	if err != nil {
+		return appv1alpha1.UnknownClusterApp, err
+	}
+
+	for _, ca := range apps {
+		if n := ca.String(); n == name || n == "gec-"+name {
+			return ca, nil
+		}
+	}
+
+	return appv1alpha1.UnknownClusterApp, fmt.Errorf("cluster/%s has no app %q", app.Name, name)
+}
+
+// status prints each app's version and readiness, along with anything
+// held back for a maintenance window
+func (p *plugin) status(ctx context.Context, name string) error {
+	app, err := p.cluster(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	apps, err := app.Apps()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.out, "cluster/%s is %s\n\n", app.Name, phase(app))
+
+	suspended := make(map[string]bool)
+	for _, s := range app.Status.Suspended {
+		suspended[s] = true
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "APP\tVERSION\tREADY\tREPLICAS")
+
+	for _, ca := range apps {
+		s := app.Status.App(ca)
+
+		ready := fmt.Sprint(s.Ready)
+		if suspended[ca.String()] {
+			ready = "suspended"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\n", ca, s.Version, ready, s.ReadyReplicas, s.Replicas)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	if len(app.Status.PendingChanges) == 0 {
+		return nil
+	}
+
+	fmt.Fprint(p.out, "\nPending changes")
+	if next := app.Status.NextMaintenanceWindow; next != nil {
+		fmt.Fprintf(p.out, ", applied from %s", next.UTC().Format("2006-01-02 15:04 MST"))
+	}
+
+	fmt.Fprintln(p.out, ":")
+
+	for _, pc := range app.Status.PendingChanges {
+		fmt.Fprintf(p.out, "  %s: %s/%s\n", pc.App, pc.Kind, pc.Name)
+	}
+
+	return nil
+}
+
+func phase(app *appv1alpha1.Cluster) appv1alpha1.ClusterPhase {
+	if app.Status.Phase == "" {
+		return appv1alpha1.ClusterPending
+	}
+
+	return app.Status.Phase
+}
+
+// upgrade sets the version an app runs
+func (p *plugin) upgrade(ctx context.Context, name, appName, version string) error {
+	app, err := p.cluster(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	ca, err := lookupApp(app, appName)
+	if err != nil {
+		return err
+	}
+
+	switch ca {
+	case appv1alpha1.ClusterBot:
+		app.Spec.Bot.Version = version
+	case appv1alpha1.ClusterProcessor:
+		app.Spec.Processor.Version = version
+	case appv1alpha1.ClusterSlacker:
+		app.Spec.Slacker.Version = version
+	default:
+		for i := range app.Spec.Components {
+			if app.Spec.Components[i].Name == ca.String() {
+				app.Spec.Components[i].Version = version
+			}
+		}
+	}
+
+	err = p.client.Update(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(p.out, "cluster/%s %s upgraded to %s\n", app.Name, ca, version)
+
+	return nil
+}
+
+func (p *plugin) setPaused(ctx context.Context, name string, paused bool) error {
+	app, err := p.cluster(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	app.Spec.Paused = paused
+
+	err = p.client.Update(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	if paused {
+		fmt.Fprintf(p.out, "cluster/%s paused\n", app.Name)
+	} else {
+		fmt.Fprintf(p.out, "cluster/%s resumed\n", app.Name)
+	}
+
+	return nil
+}
+
+// sbom prints the SBOM published for the version an app runs
+func (p *plugin) sbom(ctx context.Context, name, appName string) error {
+	app, err := p.cluster(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	ca, err := lookupApp(app, appName)
+	if err != nil {
+		return err
+	}
+
+	var url string
+
+	switch ca {
+	case appv1alpha1.ClusterBot:
+		url = app.Spec.Bot.SBOM()
+	case appv1alpha1.ClusterProcessor:
+		url = app.Spec.Processor.SBOM()
+	case appv1alpha1.ClusterSlacker:
+		url = app.Spec.Slacker.SBOM()
+	default:
+		return fmt.Errorf("%s publishes no SBOM", ca)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+
+	_, err = io.Copy(p.out, resp.Body)
+
+	return err
+}
+
+// selectors returns the labels selecting an app's pods
+func selectors(app *appv1alpha1.Cluster, ca appv1alpha1.ClusterApp) map[string]string {
+	switch ca {
+	case appv1alpha1.ClusterBot:
+		return controllers.GecBotSelectors(app)
+	case appv1alpha1.ClusterProcessor:
+		return controllers.GecProcessorSelectors(app)
+	case appv1alpha1.ClusterSlacker:
+		return controllers.GecSlackerSelectors(app)
+	}
+
+	return controllers.ComponentSelectors(app, ca)
+}
+
+// logs prints the logs of each of an app's pods, prefixing each line
+// with its pod's name where there's more than one
+func (p *plugin) logs(ctx context.Context, name, appName string, follow bool) error {
+	app, err := p.cluster(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	ca, err := lookupApp(app, appName)
+	if err != nil {
+		return err
+	}
+
+	pods := new(corev1.PodList)
+
+	err = p.client.List(ctx, pods, client.InNamespace(app.Namespace), client.MatchingLabels(selectors(app, ca)))
+	if err != nil {
+		return err
+	}
+
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("%s has no pods", ca)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make([]error, len(pods.Items))
+	)
+
+	for i, pod := range pods.Items {
+		prefix := ""
+		if len(pods.Items) > 1 {
+			prefix = fmt.Sprintf("[%s] ", pod.Name)
+		}
+
+		wg.Add(1)
+
+		go func(i int, pod string) {
+			defer wg.Done()
+
+			errs[i] = p.podLogs(ctx, pod, app.InClusterName(ca), follow, prefix, &mu)
+		}(i, pod.Name)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *plugin) podLogs(ctx context.Context, pod, container string, follow bool, prefix string, mu *sync.Mutex) error {
+	stream, err := p.clientset.CoreV1().Pods(p.namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container: container,
+		Follow:    follow,
+	}).Stream(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		mu.Lock()
+		fmt.Fprintf(p.out, "%s%s\n", prefix, scanner.Text())
+		mu.Unlock()
+	}
+
+	return scanner.Err()
+}
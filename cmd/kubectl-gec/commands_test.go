@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+)
+
+func testCluster() *appv1alpha1.Cluster {
+	return &appv1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-test-cluster",
+			Namespace: "testing",
+		},
+		Spec: appv1alpha1.ClusterSpec{
+			Bot:       appv1alpha1.Bot{App: appv1alpha1.App{Version: "v0.0.1"}},
+			Processor: appv1alpha1.Processor{App: appv1alpha1.App{Version: "v0.0.2"}},
+			Slacker:   appv1alpha1.Slacker{App: appv1alpha1.App{Version: "v0.0.3"}},
+			Components: []appv1alpha1.Component{
+				{Name: "gec-translator", Image: "gec-translator", App: appv1alpha1.App{Version: "v0.1.0"}},
+			},
+		},
+		Status: appv1alpha1.ClusterStatus{
+			Phase:     appv1alpha1.ClusterProgressing,
+			Bot:       appv1alpha1.AppStatus{Version: "v0.0.1", Replicas: 1, ReadyReplicas: 1, Ready: true},
+			Processor: appv1alpha1.AppStatus{Version: "v0.0.2", Replicas: 1},
+			Suspended: []string{"gec-slacker"},
+			PendingChanges: []appv1alpha1.PendingChange{
+				{App: "gec-processor", Kind: "Deployment", Name: "my-test-cluster-gec-processor"},
+			},
+		},
+	}
+}
+
+func testPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "testing",
+			Labels:    map[string]string{"cluster": "my-test-cluster", "app": "gec-bot"},
+		},
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func testPlugin(t *testing.T) (*plugin, *bytes.Buffer) {
+	t.Helper()
+
+	out := new(bytes.Buffer)
+
+	return &plugin{
+		client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(testCluster(), testPod("bot-1"), testPod("bot-2")).
+			Build(),
+		clientset: k8sfake.NewSimpleClientset(testPod("bot-1"), testPod("bot-2")),
+		namespace: "testing",
+		out:       out,
+		http: &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"url":"` + r.URL.String() + `"}`)),
+			}, nil
+		})},
+	}, out
+}
+
+func TestLookupApp(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		expect      string
+		expectError bool
+	}{
+		{"bot", "gec-bot", false},
+		{"gec-processor", "gec-processor", false},
+		{"translator", "gec-translator", false},
+		{"meta", "", true},
+		{"nonsuch", "", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ca, err := lookupApp(testCluster(), test.name)
+			if err == nil && test.expectError {
+				t.Fatalf("expected error")
+			} else if err != nil && !test.expectError {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if !test.expectError && ca.String() != test.expect {
+				t.Errorf("expected %q, received %q", test.expect, ca)
+			}
+		})
+	}
+}
+
+func TestPlugin_Run(t *testing.T) {
+	for _, test := range []struct {
+		command     string
+		args        []string
+		expect      []string
+		expectError bool
+	}{
+		{"status", []string{"my-test-cluster"}, []string{
+			"cluster/my-test-cluster is Progressing",
+			"gec-bot",
+			"true",
+			"1/1",
+			"suspended",
+			"gec-translator",
+			"gec-processor: Deployment/my-test-cluster-gec-processor",
+		}, false},
+		{"upgrade", []string{"my-test-cluster", "bot", "v0.0.9"}, []string{"cluster/my-test-cluster gec-bot upgraded to v0.0.9"}, false},
+		{"pause", []string{"my-test-cluster"}, []string{"cluster/my-test-cluster paused"}, false},
+		{"resume", []string{"my-test-cluster"}, []string{"cluster/my-test-cluster resumed"}, false},
+		{"sbom", []string{"my-test-cluster", "processor"}, []string{"gec-processor/releases/download/v0.0.2/bom.json"}, false},
+		{"sbom", []string{"my-test-cluster", "translator"}, nil, true},
+		{"logs", []string{"my-test-cluster", "bot"}, []string{"[bot-1] fake logs", "[bot-2] fake logs"}, false},
+		{"logs", []string{"-f", "my-test-cluster", "bot"}, []string{"[bot-1] fake logs"}, false},
+		{"logs", []string{"my-test-cluster", "slacker"}, nil, true},
+		{"status", []string{"nonsuch"}, nil, true},
+		{"upgrade", []string{"my-test-cluster", "bot"}, nil, true},
+		{"nonsuch", nil, nil, true},
+	} {
+		t.Run(test.command+" "+strings.Join(test.args, " "), func(t *testing.T) {
+			p, out := testPlugin(t)
+
+			err := p.run(context.Background(), test.command, test.args)
+			if err == nil && test.expectError {
+				t.Fatalf("expected error")
+			} else if err != nil && !test.expectError {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			for _, e := range test.expect {
+				if !strings.Contains(out.String(), e) {
+					t.Errorf("expected output to contain %q, received\n%s", e, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestPlugin_Upgrade(t *testing.T) {
+	p, _ := testPlugin(t)
+
+	for _, args := range [][]string{
+		{"my-test-cluster", "slacker", "v0.0.9"},
+		{"my-test-cluster", "translator", "v0.2.0"},
+	} {
+		err := p.run(context.Background(), "upgrade", args)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	}
+
+	app := new(appv1alpha1.Cluster)
+
+	err := p.client.Get(context.Background(), types.NamespacedName{Name: "my-test-cluster", Namespace: "testing"}, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if app.Spec.Slacker.Version != "v0.0.9" {
+		t.Errorf("expected slacker v0.0.9, received %q", app.Spec.Slacker.Version)
+	}
+
+	if app.Spec.Components[0].Version != "v0.2.0" {
+		t.Errorf("expected translator v0.2.0, received %q", app.Spec.Components[0].Version)
+	}
+
+	if app.Spec.Bot.Version != "v0.0.1" {
+		t.Errorf("expected bot to be left alone, received %q", app.Spec.Bot.Version)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-gec is a kubectl plugin for day-to-day operations on GEC
+// Clusters. Installed onto the PATH, it runs as `kubectl gec`
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(appv1alpha1.AddToScheme(scheme))
+}
+
+const usage = `Usage: kubectl gec [flags] <command> <cluster> [args]
+
+Commands:
+  status <cluster>                    Show the health of each of a Cluster's apps
+  upgrade <cluster> <app> <version>   Set the version of an app
+  pause <cluster>                     Stop the operator reconciling a Cluster
+  resume <cluster>                    Start reconciling a paused Cluster again
+  sbom <cluster> <app>                Print the SBOM of the version an app runs
+  logs [-f] <cluster> <app>           Print the logs of an app's pods
+
+Apps are named bot, processor, slacker, or by the name of a component.
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("kubectl-gec", flag.ExitOnError)
+	kubeconfig := flags.String("kubeconfig", "", "Path to the kubeconfig file to use.")
+	kubecontext := flags.String("context", "", "The kubeconfig context to use.")
+	namespace := flags.String("namespace", "", "The namespace the Cluster is in. Defaults to the context's namespace.")
+	flags.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	// Errors exit, as per flag.ExitOnError
+	_ = flags.Parse(os.Args[1:])
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = *kubeconfig
+
+	kc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: *kubecontext})
+
+	p, err := newPlugin(kc, *namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	err = p.run(ctx, flags.Arg(0), flags.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newPlugin(kc clientcmd.ClientConfig, namespace string) (p *plugin, err error) {
+	cfg, err := kc.ClientConfig()
+	if err != nil {
+		return
+	}
+
+	if namespace == "" {
+		namespace, _, err = kc.Namespace()
+		if err != nil {
+			return
+		}
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return
+	}
+
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return
+	}
+
+	return &plugin{
+		client:    c,
+		clientset: cs,
+		namespace: namespace,
+		out:       os.Stdout,
+		http:      http.DefaultClient,
+	}, nil
+}
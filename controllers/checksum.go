@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// configChecksumAnnotation is stamped onto pod templates so that changes
+// to anything an app reads through EnvFrom roll that app's pods
+const configChecksumAnnotation = "app.gec/config-checksum"
+
+func overrideName(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp) string {
+	return fmt.Sprintf("%s-override", app.InClusterName(ca))
+}
+
+// configChecksum returns a checksum of everything feeding an app's
+// EnvFrom; the generated config, and the optional override ConfigMap
+// and Secret
+func configChecksum(ctx context.Context, c client.Client, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, config map[string]string) (sum string, err error) {
+	h := sha256.New()
+
+	writeMap(h, "generated", config)
+
+	cm := new(corev1.ConfigMap)
+
+	err = c.Get(ctx, types.NamespacedName{Name: overrideName(app, ca), Namespace: app.Namespace}, cm)
+	if err != nil && !errors.IsNotFound(err) {
+		return
+	}
+
+	if err == nil {
+		writeMap(h, "configmap", cm.Data)
+	}
+
+	secret := new(corev1.Secret)
+
+	err = c.Get(ctx, types.NamespacedName{Name: overrideName(app, ca), Namespace: app.Namespace}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return
+	}
+
+	if err == nil {
+		sd := make(map[string]string, len(secret.Data))
+		for k, v := range secret.Data {
+			sd[k] = string(v)
+		}
+
+		writeMap(h, "secret", sd)
+	}
+
+	// Apps read redis' password from the environment, so only pick up a
+	// new one when they're rolled
+	if password := redisFrom(ctx).password; password != nil {
+		writeMap(h, "redis", map[string]string{redisPasswordKey: string(password)})
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// writeMap writes a map to h in a stable order
+func writeMap(h interface{ Write([]byte) (int, error) }, section string, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	fmt.Fprintf(h, "[%s]\x00", section)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\x00", k, m[k])
+	}
+}
+
+// configMapToClusters maps an override ConfigMap to the Cluster it
+// overrides. Generated ConfigMaps are owned, and so already watched
+func (r *ClusterReconciler) configMapToClusters(o client.Object) (requests []reconcile.Request) {
+	clusters := new(deploymentv1alpha1.ClusterList)
+
+	err := r.List(context.Background(), clusters, client.InNamespace(o.GetNamespace()))
+	if err != nil {
+		return
+	}
+
+	for _, cluster := range clusters.Items {
+		if isOverride(&cluster, o.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace},
+			})
+		}
+	}
+
+	return
+}
+
+func isOverride(app *deploymentv1alpha1.Cluster, name string) bool {
+	apps, _ := app.Apps()
+
+	for _, ca := range apps {
+		if overrideName(app, ca) == name {
+			return true
+		}
+	}
+
+	return false
+}
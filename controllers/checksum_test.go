@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testConfigMap(name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "testing",
+		},
+		Data: data,
+	}
+}
+
+func TestConfigChecksum(t *testing.T) {
+	sum := func(config map[string]string, objs ...client.Object) string {
+		c := fake.NewClientBuilder().
+			WithScheme(testScheme(t)).
+			WithObjects(objs...).
+			Build()
+
+		s, err := configChecksum(context.Background(), c, bot, deploymentv1alpha1.ClusterBot, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	}
+
+	base := sum(map[string]string{"REDIS_ADDR": "redis:6379"})
+
+	for _, test := range []struct {
+		name   string
+		config map[string]string
+		objs   []client.Object
+	}{
+		{"generated config", map[string]string{"REDIS_ADDR": "redis:6380"}, nil},
+		{"override configmap", map[string]string{"REDIS_ADDR": "redis:6379"}, []client.Object{testConfigMap("my-test-cluster-gec-bot-override", map[string]string{"LOG_LEVEL": "debug"})}},
+		{"override secret", map[string]string{"REDIS_ADDR": "redis:6379"}, []client.Object{testSecret("my-test-cluster-gec-bot-override", map[string]string{"TOKEN": "abc"})}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := sum(test.config, test.objs...)
+			if base == received {
+				t.Errorf("expected checksum to change from %q", base)
+			}
+		})
+	}
+
+	t.Run("unrelated objects", func(t *testing.T) {
+		received := sum(map[string]string{"REDIS_ADDR": "redis:6379"}, testConfigMap("my-test-cluster-gec-slacker-override", map[string]string{"LOG_LEVEL": "debug"}))
+		if base != received {
+			t.Errorf("expected %q, received %q", base, received)
+		}
+	})
+}
+
+func TestClusterReconciler_ConfigMapToClusters(t *testing.T) {
+	c := fake.NewClientBuilder().
+		WithScheme(testScheme(t)).
+		WithObjects(bot.DeepCopy()).
+		Build()
+
+	r := &ClusterReconciler{Client: c, Scheme: c.Scheme()}
+
+	for _, test := range []struct {
+		name   string
+		expect int
+	}{
+		{"my-test-cluster-gec-bot-override", 1},
+		{"my-test-cluster-gec-slacker-override", 1},
+		{"my-test-cluster-gec-bot", 0},
+		{"some-other-cluster-gec-bot-override", 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := r.configMapToClusters(testConfigMap(test.name, nil))
+			if test.expect != len(received) {
+				t.Errorf("expected %d requests, received %d", test.expect, len(received))
+			}
+
+			received = r.secretToClusters(testSecret(test.name, nil))
+			if test.expect != len(received) {
+				t.Errorf("expected %d requests, received %d", test.expect, len(received))
+			}
+		})
+	}
+}
@@ -26,11 +26,21 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
 )
@@ -39,6 +49,66 @@ import (
 type ClusterReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// MaxConcurrentReconciles is how many Clusters may be reconciled at
+	// once. Zero means one
+	MaxConcurrentReconciles int
+
+	// RateLimiter limits how often Clusters are retried after failing.
+	// Nil means NewRateLimiter's defaults
+	RateLimiter workqueue.RateLimiter
+
+	// FullResyncPeriod is the longest a Cluster goes without every one
+	// of its apps being reconciled. Zero means defaultFullResyncPeriod
+	FullResyncPeriod time.Duration
+
+	// Plan has every Cluster planned, rather than reconciled, as though
+	// each had appv1alpha1.PlanAnnotation set
+	Plan bool
+
+	// Recorder records Events against Clusters. Nil records nothing
+	Recorder record.EventRecorder
+
+	// SharedCache serves the Services and Secrets Clusters reference
+	// redis through, where they're in namespaces the manager's own cache
+	// doesn't cover. Nil reads them through the manager's cache
+	SharedCache cache.Cache
+
+	// SharedNamespaces are the namespaces SharedCache covers
+	SharedNamespaces []string
+
+	// LookupHost resolves the host of a Cluster's RedisURL, where it's
+	// outside of the cluster, so that apps' egress can be limited to it.
+	// Nil leaves egress to such hosts open on redis' port
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+
+	// Defaults are the operator-wide defaults apps are built with. Nil
+	// means appv1alpha1.BuiltinDefaults
+	Defaults *appv1alpha1.Defaults
+
+	// RegistryTokens are the ECR and GCR tokens Clusters may have the
+	// operator fetch. The zero value allows none
+	RegistryTokens RegistryTokens
+
+	// RedisPreflight checks a Cluster's redis is reachable before a full
+	// reconcile. Failures are recorded, but don't stop the reconcile. Nil
+	// skips the check
+	RedisPreflight func(ctx context.Context, addr string) error
+
+	pending pendingApps
+}
+
+// defaultFullResyncPeriod is the safety net for events which only cause
+// part of a Cluster to be reconciled
+const defaultFullResyncPeriod = 10 * time.Minute
+
+// reconcileOrder returns the order in which a Cluster's apps are
+// reconciled. Meta comes first so that pull secrets exist before any pods
+// need them
+func reconcileOrder(app *appv1alpha1.Cluster) ([]appv1alpha1.ClusterApp, error) {
+	apps, err := app.Apps()
+
+	return append([]appv1alpha1.ClusterApp{appv1alpha1.ClusterMeta}, apps...), err
 }
 
 //+kubebuilder:rbac:groups=app.gec,resources=clusters,verbs=get;list;watch;create;update;patch;delete
@@ -46,9 +116,14 @@ type ClusterReconciler struct {
 //+kubebuilder:rbac:groups=app.gec,resources=clusters/finalizers,verbs=update
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -56,6 +131,17 @@ type ClusterReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.12.2/pkg/reconcile
 func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx = withTraceAttributes(ctx, clusterNameKey.String(req.Name), clusterNamespaceKey.String(req.Namespace))
+
+	ctx, span := startSpan(ctx, "Reconcile")
+	res, err := r.reconcile(ctx, req)
+	endSpan(span, err)
+
+	return res, err
+}
+
+// reconcile does the work of Reconcile, within its span
+func (r *ClusterReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := ctrllog.FromContext(ctx)
 
 	app := new(appv1alpha1.Cluster)
@@ -66,6 +152,7 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
 			log.Info("No consumer object found, probably just been deleted /shrug")
+			r.pending.forget(req.NamespacedName)
 
 			return ctrl.Result{}, nil
 		}
@@ -76,35 +163,213 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, err
 	}
 
-	requeue, err := r.Upsert(ctx, gecBotUpserters, appv1alpha1.ClusterBot, app, GecBotLabels(app), GecBotSelectors(app), map[string]string{"REDIS_ADDR": app.Spec.Config.RedisURL, "DATABASE": "/database/bot.db"})
-	if err != nil || requeue > 0 {
-		return ctrl.Result{RequeueAfter: requeue}, err
+	// Planning changes nothing, and so goes ahead even while paused
+	if r.planning(app) {
+		return r.plan(ctx, app)
+	}
+
+	// Paused Clusters are left exactly as they are, bar their status
+	if app.Spec.Paused {
+		log.V(1).Info("paused")
+
+		return ctrl.Result{}, r.UpdateStatus(ctx, app)
+	}
+
+	redis, err := r.resolveRedis(ctx, app)
+	if err != nil {
+		log.Error(err, "Unable to resolve redis")
+		(&events{recorder: r.Recorder, cluster: app}).warning(ReasonRedisUnresolved, "%v", err)
+
+		return ctrl.Result{}, err
+	}
+
+	ctx = context.WithValue(ctx, "redis", redis)
+	ctx = context.WithValue(ctx, "defaults", r.defaults())
+	ctx = context.WithValue(ctx, "registry", r.RegistryTokens)
+
+	// Apps are built from the address redis resolved to, which is only
+	// written to the Cluster's status once they've been reconciled
+	desired := redis.apply(app)
+
+	apps := r.pending.take(app, r.fullResyncPeriod())
+
+	full := apps == nil
+	if full {
+		apps, err = reconcileOrder(app)
+		if err != nil {
+			log.Error(err, "Invalid components")
+
+			return ctrl.Result{}, err
+		}
+	}
+
+	log.V(1).Info("reconciling", "apps", apps, "full", full)
+
+	gate, err := newMaintenance(app, now())
+	if err != nil {
+		log.Error(err, "Invalid maintenance windows")
+
+		return ctrl.Result{}, err
+	}
+
+	ctx = context.WithValue(ctx, "maintenance", gate)
+	ctx = context.WithValue(ctx, "events", &events{recorder: r.Recorder, cluster: app})
+
+	if full && r.RedisPreflight != nil {
+		err = r.RedisPreflight(ctx, desired.RedisAddr())
+		if err != nil {
+			log.Error(err, "Redis preflight failed")
+			eventsFrom(ctx).warning(ReasonRedisPreflightFailed, "%v", err)
+		}
+	}
+
+	for _, ca := range apps {
+		gate.reconciling(ca)
+
+		requeue, err := r.reconcileApp(ctx, desired, ca)
+		if halt(ctx, requeue, err) {
+			return ctrl.Result{RequeueAfter: requeue}, err
+		}
+	}
+
+	err = r.UpdateStatus(ctx, app)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if full {
+		r.pending.done(app)
+
+		// Everything held back has now been applied, so there's nothing
+		// left for the annotation to force
+		if _, ok := app.Annotations[appv1alpha1.ApplyNowAnnotation]; ok {
+			patch := client.MergeFrom(app.DeepCopy())
+			delete(app.Annotations, appv1alpha1.ApplyNowAnnotation)
+
+			err = r.Patch(ctx, app, patch)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 	}
 
-	requeue, err = r.Upsert(ctx, gecProcessorUpserters, appv1alpha1.ClusterProcessor, app, GecProcessorLabels(app), GecProcessorSelectors(app), map[string]string{"REDIS_HOSTNAME": redisHostname(app.Spec.Config.RedisURL)})
-	if err != nil || requeue > 0 {
-		return ctrl.Result{RequeueAfter: requeue}, err
+	return ctrl.Result{RequeueAfter: r.requeueAfter(ctx, app)}, nil
+}
+
+// reconcileApp runs the upserters for a single ClusterApp
+func (r *ClusterReconciler) reconcileApp(ctx context.Context, app *appv1alpha1.Cluster, ca appv1alpha1.ClusterApp) (requeue time.Duration, err error) {
+	ctx = withTraceAttributes(ctx, appKey.String(ca.String()))
+
+	ctx, span := startSpan(ctx, "Reconcile "+ca.String())
+	defer func() { endSpan(span, err) }()
+
+	if ca == appv1alpha1.ClusterMeta {
+		return r.reconcileMeta(ctx, app)
 	}
 
-	requeue, err = r.Upsert(ctx, gecSlackerUpserters, appv1alpha1.ClusterSlacker, app, GecSlackerLabels(app), GecSlackerSelectors(app), map[string]string{"REDIS_ADDR": app.Spec.Config.RedisURL, "INCOMING_STREAM": "gec-processed", "OUTGOING_STREAM": "gec-responses"})
+	c, ok := componentFor(ca)
+	if !ok {
+		return
+	}
+
+	return r.Upsert(ctx, c.upserters, ca, app, c.labels(app), c.selectors(app), c.config(app))
+}
+
+// reconcileMeta reconciles the objects which belong to a Cluster as a
+// whole, rather than to any one app
+func (r *ClusterReconciler) reconcileMeta(ctx context.Context, app *appv1alpha1.Cluster) (requeue time.Duration, err error) {
+	// Pull secrets and redis' password come first, so that they exist
+	// before any pods which need them are scheduled. They hold live
+	// credentials, and so aren't rendered
+	if !rendering(ctx) {
+		requeue, err = r.upsert(ctx, RegistrySecret, app, appv1alpha1.ClusterMeta, GecMetaLabels(app), nil)
+		if halt(ctx, requeue, err) {
+			return
+		}
+
+		requeue, err = r.upsert(ctx, RedisSecret, app, appv1alpha1.ClusterMeta, GecMetaLabels(app), nil)
+		if halt(ctx, requeue, err) {
+			return
+		}
+	}
 
-	// Write final status
 	ctx = context.WithValue(ctx, "config", map[string]string{
 		"bot_sbom":       app.Spec.Bot.SBOM(),
 		"processor_sbom": app.Spec.Processor.SBOM(),
 		"slacker_bom":    app.Spec.Slacker.SBOM(),
 	})
 
-	requeue, err = ConfigMap(ctx, r.Client, r.Scheme, app, appv1alpha1.ClusterMeta, GecMetaLabels(app), nil)
+	requeue, err = r.upsert(ctx, ConfigMap, app, appv1alpha1.ClusterMeta, GecMetaLabels(app), nil)
+	if halt(ctx, requeue, err) {
+		return
+	}
+
+	// Deny everything not explicitly allowed by each app's own policy
+	requeue, err = r.upsert(ctx, NetworkPolicy, app, appv1alpha1.ClusterMeta, GecMetaLabels(app), nil)
+	if halt(ctx, requeue, err) {
+		return
+	}
+
+	// Rendered Clusters have nothing left over from earlier specs to
+	// prune
+	if rendering(ctx) {
+		return
+	}
+
+	return r.upsert(ctx, PruneComponents, app, appv1alpha1.ClusterMeta, GecMetaLabels(app), nil)
+}
+
+func (r *ClusterReconciler) fullResyncPeriod() time.Duration {
+	if r.FullResyncPeriod > 0 {
+		return r.FullResyncPeriod
+	}
+
+	return defaultFullResyncPeriod
+}
+
+func (r *ClusterReconciler) defaults() appv1alpha1.Defaults {
+	if r.Defaults != nil {
+		return *r.Defaults
+	}
+
+	return appv1alpha1.BuiltinDefaults()
+}
+
+// defaultsFrom returns the defaults apps are built with during a
+// reconcile
+func defaultsFrom(ctx context.Context) appv1alpha1.Defaults {
+	d, ok := ctx.Value("defaults").(appv1alpha1.Defaults)
+	if !ok {
+		return appv1alpha1.BuiltinDefaults()
+	}
+
+	return d
+}
 
-	return ctrl.Result{RequeueAfter: requeue}, err
+// requeueAfter returns when a Cluster should next be reconciled, regardless
+// of events: to refresh its pull secret, to apply pending changes once its
+// next maintenance window opens, or for a full resync
+func (r *ClusterReconciler) requeueAfter(ctx context.Context, app *appv1alpha1.Cluster) time.Duration {
+	d := r.fullResyncPeriod()
+
+	if refresh := r.RegistryRefreshIn(ctx, app); refresh > 0 && refresh < d {
+		d = refresh
+	}
+
+	if next := app.Status.NextMaintenanceWindow; next != nil {
+		if opens := next.Sub(now()); opens > 0 && opens < d {
+			d = opens
+		}
+	}
+
+	return d
 }
 
 func (r *ClusterReconciler) Upsert(ctx context.Context, upserters []upserter, ca appv1alpha1.ClusterApp, app *appv1alpha1.Cluster, labels, selectors, config map[string]string) (requeue time.Duration, err error) {
 	ctx = context.WithValue(ctx, "config", config)
 	for _, f := range upserters {
-		requeue, err = f(ctx, r.Client, r.Scheme, app, ca, labels, selectors)
-		if err != nil || requeue > 0 {
+		requeue, err = r.upsert(ctx, f, app, ca, labels, selectors)
+		if halt(ctx, requeue, err) {
 			return
 		}
 	}
@@ -114,12 +379,48 @@ func (r *ClusterReconciler) Upsert(ctx context.Context, upserters []upserter, ca
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&appv1alpha1.Cluster{}).
-		Owns(&appsv1.Deployment{}).
-		Owns(&corev1.ServiceAccount{}).
-		Owns(&corev1.ConfigMap{}).
-		Owns(&corev1.PersistentVolumeClaim{}).
+	rl := r.RateLimiter
+	if rl == nil {
+		rl = NewRateLimiter(0, 0, 0, 0)
+	}
+
+	// Owned objects are watched, rather than using Owns, so that events
+	// on them record which app needs reconciling
+	owned := ownedAppHandler{pending: &r.pending}
+
+	// The Services and Secrets redis is referenced through may be
+	// outside of the namespaces Clusters are watched in
+	redis := fullHandler{pending: &r.pending, inner: handler.EnqueueRequestsFromMapFunc(r.redisToClusters)}
+
+	// Status updates, including our own, don't bump a Cluster's
+	// generation and so don't retrigger a reconcile. Annotation changes
+	// do, so that ApplyNowAnnotation takes effect straight away
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&appv1alpha1.Cluster{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, owned, builder.WithPredicates(deploymentChanged{})).
+		Watches(&source.Kind{Type: &corev1.ServiceAccount{}}, owned).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, owned).
+		Watches(&source.Kind{Type: &corev1.PersistentVolumeClaim{}}, owned).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, owned).
+		Watches(&source.Kind{Type: &corev1.Service{}}, owned).
+		Watches(&source.Kind{Type: &policyv1.PodDisruptionBudget{}}, owned).
+		Watches(&source.Kind{Type: &networkingv1.NetworkPolicy{}}, owned).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, fullHandler{pending: &r.pending, inner: handler.EnqueueRequestsFromMapFunc(r.configMapToClusters)}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, fullHandler{pending: &r.pending, inner: handler.EnqueueRequestsFromMapFunc(r.secretToClusters)}).
+		Watches(&source.Kind{Type: &corev1.Service{}}, redis).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, redis)
+
+	if r.SharedCache != nil {
+		b = b.
+			Watches(source.NewKindWithCache(&corev1.Service{}, r.SharedCache), redis).
+			Watches(source.NewKindWithCache(&corev1.Secret{}, r.SharedCache), redis)
+	}
+
+	return b.
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+			RateLimiter:             rl,
+		}).
 		Complete(r)
 }
 
@@ -1,7 +1,15 @@
 package controllers
 
 import (
+	"context"
+	"reflect"
 	"testing"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestClusterReconciler_SetupWithManager(t *testing.T) {
@@ -37,3 +45,136 @@ func TestRedisHostname(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcile_Paused(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Spec.Paused = true
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	r := &ClusterReconciler{Client: c, Scheme: testScheme(t)}
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+
+	res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: nn})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if res.RequeueAfter != 0 {
+		t.Errorf("expected no requeue, received %s", res.RequeueAfter)
+	}
+
+	deployments := new(appsv1.DeploymentList)
+
+	err = c.List(context.Background(), deployments)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if len(deployments.Items) > 0 {
+		t.Errorf("expected nothing to be deployed, received %d Deployments", len(deployments.Items))
+	}
+
+	err = c.Get(context.Background(), nn, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if app.Status.Phase != appv1alpha1.ClusterPaused {
+		t.Errorf("expected %q, received %q", appv1alpha1.ClusterPaused, app.Status.Phase)
+	}
+}
+
+func TestReconcile_Suspended(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Spec.Slacker.Suspended = true
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	r := &ClusterReconciler{Client: c, Scheme: testScheme(t)}
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+
+	converge := func() {
+		t.Helper()
+
+		for i := 0; i < 5; i++ {
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: nn})
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+		}
+	}
+
+	deploymentOf := func(ca appv1alpha1.ClusterApp) *appsv1.Deployment {
+		t.Helper()
+
+		d := new(appsv1.Deployment)
+
+		err := c.Get(context.Background(), types.NamespacedName{Name: app.InClusterName(ca), Namespace: app.Namespace}, d)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		return d
+	}
+
+	scale := func(ca appv1alpha1.ClusterApp, n int32) {
+		t.Helper()
+
+		d := deploymentOf(ca)
+		d.Spec.Replicas = &n
+
+		err := c.Update(context.Background(), d)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	}
+
+	converge()
+
+	if received := replicas(deploymentOf(appv1alpha1.ClusterSlacker)); received != 0 {
+		t.Errorf("expected suspended slacker to have no replicas, received %d", received)
+	}
+
+	err := c.Get(context.Background(), nn, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if expect := []string{"gec-slacker"}; !reflect.DeepEqual(expect, app.Status.Suspended) {
+		t.Errorf("expected %v, received %v", expect, app.Status.Suspended)
+	}
+
+	// Suspended apps are kept at zero, while others can be scaled by hand
+	scale(appv1alpha1.ClusterSlacker, 3)
+	scale(appv1alpha1.ClusterProcessor, 2)
+
+	converge()
+
+	if received := replicas(deploymentOf(appv1alpha1.ClusterSlacker)); received != 0 {
+		t.Errorf("expected slacker to be scaled back to zero, received %d", received)
+	}
+
+	if received := replicas(deploymentOf(appv1alpha1.ClusterProcessor)); received != 2 {
+		t.Errorf("expected processor to keep 2 replicas, received %d", received)
+	}
+
+	// Resuming scales back up
+	err = c.Get(context.Background(), nn, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	app.Spec.Slacker.Suspended = false
+
+	err = c.Update(context.Background(), app)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	converge()
+
+	if received := replicas(deploymentOf(appv1alpha1.ClusterSlacker)); received != 1 {
+		t.Errorf("expected resumed slacker to have 1 replica, received %d", received)
+	}
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+//+kubebuilder:webhook:path=/validate-app-gec-v1alpha1-cluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=app.gec,resources=clusters,verbs=create;update,versions=v1alpha1,name=vcluster.gec.kb.io,admissionReviewVersions=v1,matchPolicy=Equivalent
+
+// ClusterValidator rejects Clusters the operator would refuse to
+// reconcile, such as those whose podTemplateOverride weakens an app's
+// security settings, so that mistakes show up on kubectl apply rather
+// than in the operator's logs.
+//
+// The reconciler makes the same checks, for Clusters admitted while the
+// webhook wasn't running
+type ClusterValidator struct {
+	// Defaults are the operator-wide defaults apps are built with. Nil
+	// means appv1alpha1.BuiltinDefaults
+	Defaults *appv1alpha1.Defaults
+
+	// SharedNamespaces are those, other than their own, Clusters may
+	// reference redis in
+	SharedNamespaces []string
+
+	// RegistryTokens are the ECR and GCR tokens Clusters may have the
+	// operator fetch. The zero value allows none
+	RegistryTokens RegistryTokens
+}
+
+// SetupWebhookWithManager registers the validating webhook. Clusters of
+// either API version are converted to v1alpha1 before they're checked
+func (v *ClusterValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(new(appv1alpha1.Cluster)).
+		WithValidator(v).
+		Complete()
+}
+
+func (v *ClusterValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return v.validate(obj)
+}
+
+func (v *ClusterValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	return v.validate(newObj)
+}
+
+func (v *ClusterValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+func (v *ClusterValidator) validate(obj runtime.Object) error {
+	app, ok := obj.(*appv1alpha1.Cluster)
+	if !ok {
+		return fmt.Errorf("expected a Cluster, received %T", obj)
+	}
+
+	return validateCluster(v.defaults(), v.SharedNamespaces, v.RegistryTokens, app)
+}
+
+func (v *ClusterValidator) defaults() appv1alpha1.Defaults {
+	if v.Defaults != nil {
+		return *v.Defaults
+	}
+
+	return appv1alpha1.BuiltinDefaults()
+}
+
+// validateCluster checks a Cluster's redis references and registry
+// credentials, and builds each of its apps as a reconcile would,
+// returning every error doing so finds
+func validateCluster(defaults appv1alpha1.Defaults, shared []string, tokens RegistryTokens, app *appv1alpha1.Cluster) error {
+	apps, err := app.Apps()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	err = checkRedisNamespaces(app, shared)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = tokens.check(app.Spec.Registry)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, ca := range apps {
+		comp, ok := componentFor(ca)
+		if !ok {
+			continue
+		}
+
+		d, err := deployment(defaults, app, ca, comp.labels(app), comp.selectors(app))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ca, err))
+
+			continue
+		}
+
+		_, err = podTemplateOverride(app, ca, d.Spec.Template)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
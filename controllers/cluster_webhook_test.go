@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestClusterValidator(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		mutate      func(*deploymentv1alpha1.Cluster)
+		expectError bool
+	}{
+		{"valid", func(*deploymentv1alpha1.Cluster) {}, false},
+		{"harmless override", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Bot.PodTemplateOverride = &runtime.RawExtension{Raw: []byte(`{"spec": {"priorityClassName": "gec-critical"}}`)}
+		}, false},
+		{"privileged override", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Processor.PodTemplateOverride = &runtime.RawExtension{Raw: []byte(`{"spec": {"hostPID": true}}`)}
+		}, true},
+		{"root override", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Slacker.PodTemplateOverride = &runtime.RawExtension{Raw: []byte(`{"spec": {"securityContext": {"runAsUser": 0}}}`)}
+		}, true},
+		{"host path extra volume", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Bot.ExtraVolumes = []corev1.Volume{
+				{Name: "docker", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"}}},
+			}
+		}, true},
+		{"reserved component", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Components = []deploymentv1alpha1.Component{{Name: "gec-bot", Image: "gec-bot"}}
+		}, true},
+		{"shared redis", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Config = sharedRedisCluster().Spec.Config
+		}, false},
+		{"unshared redis service", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Config = sharedRedisCluster().Spec.Config
+			app.Spec.Config.RedisService.Namespace = "infra"
+		}, true},
+		{"unshared redis secret", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Config.RedisPasswordSecret = &deploymentv1alpha1.RedisSecretRef{Name: "redis", Namespace: "kube-system"}
+		}, true},
+		{"allowed ecr registry", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Registry = &deploymentv1alpha1.RegistryCredentials{
+				Server: "123456789012.dkr.ecr.eu-west-2.amazonaws.com",
+				ECR:    &deploymentv1alpha1.ECRTokenSource{Region: "eu-west-2"},
+			}
+		}, false},
+		{"unlisted ecr registry", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Registry = &deploymentv1alpha1.RegistryCredentials{
+				Server: "210987654321.dkr.ecr.eu-west-2.amazonaws.com",
+				ECR:    &deploymentv1alpha1.ECRTokenSource{Region: "eu-west-2"},
+			}
+		}, true},
+		{"gcr registry", func(app *deploymentv1alpha1.Cluster) {
+			app.Spec.Registry = &deploymentv1alpha1.RegistryCredentials{
+				Server: "europe-docker.pkg.dev",
+				GCR:    &deploymentv1alpha1.GCRTokenSource{},
+			}
+		}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			app := bot.DeepCopy()
+			test.mutate(app)
+
+			v := &ClusterValidator{SharedNamespaces: []string{"data"}, RegistryTokens: ecrTokens}
+
+			err := v.ValidateCreate(context.Background(), app)
+			if test.expectError != (err != nil) {
+				t.Errorf("create: expected error %v, received %v", test.expectError, err)
+			}
+
+			err = v.ValidateUpdate(context.Background(), bot.DeepCopy(), app)
+			if test.expectError != (err != nil) {
+				t.Errorf("update: expected error %v, received %v", test.expectError, err)
+			}
+		})
+	}
+}
@@ -11,27 +11,32 @@ import (
 	"github.com/google/go-cmp/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 var (
 	Project string
+
+	// legacyPullSecrets are attached to the ServiceAccounts of Clusters
+	// without spec.identity, as they were before it existed. They're
+	// kept for one release, so that such Clusters can move their pull
+	// secrets to spec.identity.imagePullSecrets first
+	legacyPullSecrets = []corev1.LocalObjectReference{{Name: "ecr-pull"}}
 )
 
 type upserter func(context.Context, client.Client, *runtime.Scheme, *deploymentv1alpha1.Cluster, deploymentv1alpha1.ClusterApp, map[string]string, map[string]string) (time.Duration, error)
 
 func init() {
-	var err error
-
-	Project, err = getenv("PROJECT")
-	if err != nil {
-		panic(err)
-	}
+	// PROJECT is only used as the GKE project for Clusters which predate
+	// spec.identity, and so is optional
+	Project, _ = getenv("PROJECT")
 }
 
 func getenv(v string) (s string, err error) {
@@ -43,8 +48,65 @@ func getenv(v string) (s string, err error) {
 	return
 }
 
-func gcpServiceAccount(name string) string {
-	return fmt.Sprintf("%s@%s.iam.gserviceaccount.com", name, Project)
+// identity returns the cloud identity for a ClusterApp.
+//
+// An app's own identity wins over the Cluster's. Clusters without either
+// fall back to GKE Workload Identity in the project named by the PROJECT
+// environment variable, where set, and keep legacyPullSecrets
+func identity(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp) (id deploymentv1alpha1.CloudIdentity) {
+	switch {
+	case app.Spec.Identity != nil:
+		id = *app.Spec.Identity
+
+	case Project != "":
+		id = deploymentv1alpha1.CloudIdentity{
+			Provider: deploymentv1alpha1.IdentityProviderGKE,
+			GKE: &deploymentv1alpha1.GKEIdentity{
+				Project: Project,
+			},
+			ImagePullSecrets: legacyPullSecrets,
+		}
+
+	default:
+		id = deploymentv1alpha1.CloudIdentity{
+			Provider:         deploymentv1alpha1.IdentityProviderNone,
+			ImagePullSecrets: legacyPullSecrets,
+		}
+	}
+
+	override := app.InClusterApp(ca).Identity
+	if override == nil {
+		return
+	}
+
+	pullSecrets := id.ImagePullSecrets
+
+	id = *override
+	if len(id.ImagePullSecrets) == 0 {
+		id.ImagePullSecrets = pullSecrets
+	}
+
+	return
+}
+
+// podLabels returns the labels for an app's pods; its own labels, plus
+// any its cloud identity requires
+func podLabels(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels map[string]string) map[string]string {
+	extra := identity(app, ca).PodLabels()
+	if len(extra) == 0 {
+		return labels
+	}
+
+	l := make(map[string]string, len(labels)+len(extra))
+	for k, v := range labels {
+		l[k] = v
+	}
+
+	for k, v := range extra {
+		l[k] = v
+	}
+
+	return l
 }
 
 func ServiceAccount(ctx context.Context, c client.Client, s *runtime.Scheme, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
@@ -67,9 +129,14 @@ func ServiceAccount(ctx context.Context, c client.Client, s *runtime.Scheme, app
 		return
 	}
 
-	if !reflect.DeepEqual(found.ImagePullSecrets, sa.ImagePullSecrets) {
-		diff := cmp.Diff(found.ImagePullSecrets, sa.ImagePullSecrets)
-		fmt.Println(diff)
+	// Other controllers may annotate ServiceAccounts too, and so only
+	// the annotations a cloud identity sets are compared
+	annotations := ownKeys(found.Annotations, sa.Annotations, deploymentv1alpha1.IdentityAnnotations)
+
+	if !reflect.DeepEqual(found.ImagePullSecrets, sa.ImagePullSecrets) || !equalKeys(found.Annotations, annotations) {
+		ctrllog.FromContext(ctx).V(1).Info("updating ServiceAccount", "name", sa.Name, "diff", cmp.Diff(found, sa))
+
+		sa.Annotations = annotations
 
 		err = c.Update(ctx, sa)
 		if err == nil {
@@ -80,19 +147,52 @@ func ServiceAccount(ctx context.Context, c client.Client, s *runtime.Scheme, app
 	return
 }
 
+// ownKeys returns existing annotations or labels, with the owned keys
+// replaced by those in desired
+func ownKeys(existing, desired map[string]string, owned []string) map[string]string {
+	a := make(map[string]string, len(existing)+len(desired))
+	for k, v := range existing {
+		a[k] = v
+	}
+
+	for _, k := range owned {
+		delete(a, k)
+	}
+
+	for k, v := range desired {
+		a[k] = v
+	}
+
+	return a
+}
+
+// equalKeys compares annotations or labels, treating nil and empty alike
+func equalKeys(a, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
 func serviceAccount(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels map[string]string) *corev1.ServiceAccount {
+	id := identity(app, ca)
+
+	pullSecrets := id.ImagePullSecrets
+	if app.Spec.Registry != nil {
+		pullSecrets = append(append([]corev1.LocalObjectReference{}, pullSecrets...), corev1.LocalObjectReference{
+			Name: registrySecretName(app),
+		})
+	}
+
 	return &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      app.InClusterName(ca),
-			Namespace: app.Namespace,
-			Labels:    labels,
-			Annotations: map[string]string{
-				"iam.gke.io/gcp-service-account": gcpServiceAccount(app.Name),
-			},
-		},
-		ImagePullSecrets: []corev1.LocalObjectReference{
-			{Name: "ecr-pull"},
+			Name:        app.InClusterName(ca),
+			Namespace:   app.Namespace,
+			Labels:      labels,
+			Annotations: id.ServiceAccountAnnotations(*app),
 		},
+		ImagePullSecrets: pullSecrets,
 	}
 }
 
@@ -117,8 +217,7 @@ func ConfigMap(ctx context.Context, c client.Client, s *runtime.Scheme, app *dep
 	}
 
 	if !reflect.DeepEqual(found.Data, cm.Data) {
-		diff := cmp.Diff(found.Data, cm.Data)
-		fmt.Println(diff)
+		ctrllog.FromContext(ctx).V(1).Info("updating ConfigMap", "name", cm.Name, "diff", cmp.Diff(found.Data, cm.Data))
 
 		err = c.Update(ctx, cm)
 		if err == nil {
@@ -140,8 +239,39 @@ func configmap(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp
 	}
 }
 
+// templateAnnotations are the annotations the operator owns on the pod
+// templates of the Deployments it creates
+var templateAnnotations = []string{
+	configChecksumAnnotation,
+	credentialsChecksumAnnotation,
+}
+
 func Deployment(ctx context.Context, c client.Client, s *runtime.Scheme, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
-	d := deployment(app, ca, labels, selectors)
+	d, err := deployment(defaultsFrom(ctx), app, ca, labels, selectors)
+	if err != nil {
+		return
+	}
+
+	sum, err := credentialsChecksum(ctx, c, app, ca)
+	if err != nil {
+		return
+	}
+
+	d.Spec.Template.Annotations = make(map[string]string)
+	if sum != "" {
+		d.Spec.Template.Annotations[credentialsChecksumAnnotation] = sum
+	}
+
+	d.Spec.Template.Annotations[configChecksumAnnotation], err = configChecksum(ctx, c, app, ca, ctx.Value("config").(map[string]string))
+	if err != nil {
+		return
+	}
+
+	// Overrides go last, so that they apply to everything above
+	d.Spec.Template, err = podTemplateOverride(app, ca, d.Spec.Template)
+	if err != nil {
+		return
+	}
 
 	err = ctrl.SetControllerReference(app, d, s)
 	if err != nil {
@@ -157,33 +287,98 @@ func Deployment(ctx context.Context, c client.Client, s *runtime.Scheme, app *de
 			return
 		}
 
+		eventsFrom(ctx).rollout(ca, "", d.Spec.Template.Labels["version"])
+
 		return
 	}
 
-	if !reflect.DeepEqual(found.Spec.Template.Spec, d.Spec.Template.Spec) {
-		diff := cmp.Diff(found.Spec.Template.Spec, d.Spec.Template.Spec)
-		fmt.Println(diff)
+	// Other tools annotate pod templates too, such as kubectl's
+	// restartedAt, and so only the checksums the operator stamps are
+	// compared. Labels are treated the same way, so that switching cloud
+	// identity adds or removes the labels it needs
+	annotations := ownKeys(found.Spec.Template.Annotations, d.Spec.Template.Annotations, templateAnnotations)
+	templateLabels := ownKeys(found.Spec.Template.Labels, d.Spec.Template.Labels, deploymentv1alpha1.IdentityPodLabels)
+
+	rollout := !reflect.DeepEqual(found.Spec.Template.Spec, d.Spec.Template.Spec) ||
+		!equalKeys(found.Spec.Template.Annotations, annotations) ||
+		!equalKeys(found.Spec.Template.Labels, templateLabels) ||
+		!reflect.DeepEqual(found.Spec.Strategy, d.Spec.Strategy)
+
+	d.Spec.Template.Annotations = annotations
+	d.Spec.Template.Labels = templateLabels
+
+	// Replicas are otherwise left to whoever scales the app, but suspended
+	// apps are kept at zero, and scaled back up once resumed
+	scale := replicas(found) != replicas(d) && (replicas(d) == 0 || replicas(found) == 0)
+	if !scale {
+		d.Spec.Replicas = found.Spec.Replicas
+	}
+
+	// Rollouts roll the app's pods, and so wait for a maintenance window.
+	// Scaling goes ahead regardless
+	if rollout && !maintenanceFrom(ctx).allow(ca, "Deployment", d.Name) {
+		rollout = false
+
+		d.Spec.Template = found.Spec.Template
+		d.Spec.Strategy = found.Spec.Strategy
+	}
+
+	if rollout || scale {
+		ctrllog.FromContext(ctx).V(1).Info("updating Deployment", "name", d.Name, "diff", cmp.Diff(found.Spec, d.Spec))
 
 		err = c.Update(ctx, d)
-		if err == nil {
-			requeue = time.Second
+		if err != nil {
+			return
+		}
+
+		requeue = time.Second
+
+		if rollout {
+			eventsFrom(ctx).rollout(ca, found.Spec.Template.Labels["version"], d.Spec.Template.Labels["version"])
 		}
 	}
 
 	return
 }
 
-func deployment(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) *appsv1.Deployment {
+// replicas returns how many pods a Deployment wants, which the API server
+// defaults to one
+func replicas(d *appsv1.Deployment) int32 {
+	if d.Spec.Replicas == nil {
+		return 1
+	}
+
+	return *d.Spec.Replicas
+}
+
+func deployment(defaults deploymentv1alpha1.Defaults, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) (*appsv1.Deployment, error) {
 	var (
 		replicas           int32 = 1
 		optional                 = true
 		enableServiceLinks       = false
 		automountSAToken         = false
 		terminationGrace   int64 = 30
-		trueVal                  = true
-		falseVal                 = false
 	)
 
+	a := app.InClusterApp(ca)
+
+	probes, err := app.InClusterProbes(ca)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Suspended {
+		replicas = 0
+	}
+
+	extras, err := extraVolumes(a.ExtraVolumes)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := app.InClusterCredentials(ca)
+	credVolumes, credMounts := credentialsVolumes(creds)
+
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      app.InClusterName(ca),
@@ -192,23 +387,29 @@ func deployment(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterAp
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &replicas,
+			Strategy: app.InClusterStrategy(ca),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: selectors,
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
+					Labels: podLabels(app, ca, labels),
 				},
 				Spec: corev1.PodSpec{
 					ServiceAccountName: app.InClusterName(ca),
-					Containers: []corev1.Container{{
-						Image: app.InClusterImage(ca),
+					Containers: append([]corev1.Container{{
+						Image: app.InClusterImage(defaults, ca),
 						Name:  app.InClusterName(ca),
+						Ports: containerPorts(a.Ports),
 						Resources: corev1.ResourceRequirements{
-							Limits:   ca.Resources(),
-							Requests: ca.Resources(),
+							Limits:   app.InClusterResources(defaults, ca),
+							Requests: app.InClusterResources(defaults, ca),
 						},
-						VolumeMounts: ca.VolumeMount(app.InClusterName(ca)),
+						Env:            append(append(credentialsEnv(creds), redisEnv(app)...), extraEnv(a.ExtraEnv)...),
+						VolumeMounts:   append(append(app.InClusterVolumeMount(ca), credMounts...), a.ExtraVolumeMounts...),
+						LivenessProbe:  probes.Liveness,
+						ReadinessProbe: probes.Readiness,
+						StartupProbe:   probes.Startup,
 						EnvFrom: []corev1.EnvFromSource{
 							{
 								ConfigMapRef: &corev1.ConfigMapEnvSource{
@@ -220,7 +421,7 @@ func deployment(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterAp
 							{
 								ConfigMapRef: &corev1.ConfigMapEnvSource{
 									LocalObjectReference: corev1.LocalObjectReference{
-										Name: fmt.Sprintf("%s-override", app.InClusterName(ca)),
+										Name: overrideName(app, ca),
 									},
 									Optional: &optional,
 								},
@@ -228,7 +429,7 @@ func deployment(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterAp
 							{
 								SecretRef: &corev1.SecretEnvSource{
 									LocalObjectReference: corev1.LocalObjectReference{
-										Name: fmt.Sprintf("%s-override", app.InClusterName(ca)),
+										Name: overrideName(app, ca),
 									},
 									Optional: &optional,
 								},
@@ -238,36 +439,101 @@ func deployment(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterAp
 						TerminationMessagePolicy: corev1.TerminationMessageReadFile,
 						TTY:                      false,
 						ImagePullPolicy:          corev1.PullIfNotPresent,
-						SecurityContext: &corev1.SecurityContext{
-							ReadOnlyRootFilesystem: &trueVal,
-							Privileged:             &falseVal,
-							Capabilities: &corev1.Capabilities{
-								Drop: []corev1.Capability{"ALL"},
-							},
-							AllowPrivilegeEscalation: &falseVal,
-							RunAsNonRoot:             &trueVal,
-							SeccompProfile: &corev1.SeccompProfile{
-								Type: corev1.SeccompProfileTypeRuntimeDefault,
-							},
-						},
-					}},
+						SecurityContext:          containerSecurityContext(nil),
+					}}, extraContainers(a.ExtraContainers)...),
+					InitContainers:                extraContainers(a.InitContainers),
 					RestartPolicy:                 corev1.RestartPolicyAlways,
 					TerminationGracePeriodSeconds: &terminationGrace,
 					DNSPolicy:                     corev1.DNSClusterFirst,
 					DeprecatedServiceAccount:      app.InClusterName(ca),
 					SecurityContext:               &corev1.PodSecurityContext{},
 					SchedulerName:                 "default-scheduler",
-					Volumes:                       ca.Volume(app.InClusterName(ca)),
+					Volumes:                       append(append(app.InClusterVolume(defaults, ca), credVolumes...), extras...),
 					EnableServiceLinks:            &enableServiceLinks,
 					AutomountServiceAccountToken:  &automountSAToken,
 				},
 			},
 		},
+	}, nil
+}
+
+func PodDisruptionBudget(ctx context.Context, c client.Client, s *runtime.Scheme, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
+	pdb := podDisruptionBudget(app, ca, labels, selectors)
+
+	err = ctrl.SetControllerReference(app, pdb, s)
+	if err != nil {
+		return
+	}
+
+	found := &policyv1.PodDisruptionBudget{}
+
+	err = c.Get(ctx, types.NamespacedName{Name: pdb.Name, Namespace: app.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		err = c.Create(ctx, pdb)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(found.Spec, pdb.Spec) {
+		ctrllog.FromContext(ctx).V(1).Info("updating PodDisruptionBudget", "name", pdb.Name, "diff", cmp.Diff(found.Spec, pdb.Spec))
+
+		pdb.ResourceVersion = found.ResourceVersion
+
+		err = c.Update(ctx, pdb)
+		if err == nil {
+			requeue = time.Second
+		}
+	}
+
+	return
+}
+
+func podDisruptionBudget(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) *policyv1.PodDisruptionBudget {
+	db := app.InClusterDisruptionBudget(ca)
+
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.InClusterName(ca),
+			Namespace: app.Namespace,
+			Labels:    labels,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selectors,
+			},
+			MinAvailable:   db.MinAvailable,
+			MaxUnavailable: db.MaxUnavailable,
+		},
 	}
 }
 
+// containerPorts fills in the protocol the API server would otherwise
+// default, so that comparisons against the live Deployment hold
+func containerPorts(ports []corev1.ContainerPort) []corev1.ContainerPort {
+	if len(ports) == 0 {
+		return nil
+	}
+
+	out := make([]corev1.ContainerPort, len(ports))
+	for i, p := range ports {
+		out[i] = p
+		if out[i].Protocol == "" {
+			out[i].Protocol = corev1.ProtocolTCP
+		}
+	}
+
+	return out
+}
+
 func PVC(ctx context.Context, c client.Client, s *runtime.Scheme, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
-	p := pvc(app, ca, labels)
+	if !app.InClusterDefinition(ca).Database {
+		return
+	}
+
+	p := pvc(defaultsFrom(ctx), app, ca, labels)
 
 	err = ctrl.SetControllerReference(app, p, s)
 	if err != nil {
@@ -277,14 +543,38 @@ func PVC(ctx context.Context, c client.Client, s *runtime.Scheme, app *deploymen
 	found := &corev1.PersistentVolumeClaim{}
 
 	err = c.Get(ctx, types.NamespacedName{Name: p.Name, Namespace: app.Namespace}, found)
-	if err != nil && errors.IsNotFound(err) {
-		err = c.Create(ctx, p)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			err = c.Create(ctx, p)
+		}
+
+		return
+	}
+
+	// Volumes can only grow, and resizing them can mean remounting them,
+	// so resizes wait for a maintenance window
+	size := p.Spec.Resources.Requests[corev1.ResourceStorage]
+	current := found.Spec.Resources.Requests[corev1.ResourceStorage]
+
+	if current.Cmp(size) >= 0 || !maintenanceFrom(ctx).allow(ca, "PersistentVolumeClaim", p.Name) {
+		return
+	}
+
+	if found.Spec.Resources.Requests == nil {
+		found.Spec.Resources.Requests = make(corev1.ResourceList)
+	}
+
+	found.Spec.Resources.Requests[corev1.ResourceStorage] = size
+
+	err = c.Update(ctx, found)
+	if err == nil {
+		requeue = time.Second
 	}
 
 	return
 }
 
-func pvc(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels map[string]string) *corev1.PersistentVolumeClaim {
+func pvc(defaults deploymentv1alpha1.Defaults, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels map[string]string) *corev1.PersistentVolumeClaim {
 	return &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      app.InClusterName(ca),
@@ -297,7 +587,7 @@ func pvc(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labe
 			},
 			Resources: corev1.ResourceRequirements{
 				Requests: corev1.ResourceList{
-					corev1.ResourceStorage: deploymentv1alpha1.VolumeSize,
+					corev1.ResourceStorage: defaults.VolumeSize,
 				},
 			},
 		},
@@ -1,10 +1,31 @@
 package controllers
 
 import (
+	"context"
 	"os"
+	"reflect"
 	"testing"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
+// mustDeployment builds the Deployment for a ClusterApp, failing t where
+// it can't be built
+func mustDeployment(t *testing.T, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) *appsv1.Deployment {
+	t.Helper()
+
+	d, err := deployment(deploymentv1alpha1.BuiltinDefaults(), app, ca, labels, selectors)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	return d
+}
+
 func TestGetenv_PROJECT(t *testing.T) {
 	oldProject := Project
 	defer func() {
@@ -18,3 +39,309 @@ func TestGetenv_PROJECT(t *testing.T) {
 		t.Error("expected error")
 	}
 }
+
+func TestIdentity(t *testing.T) {
+	oldProject := Project
+	defer func() {
+		Project = oldProject
+	}()
+
+	app := bot.DeepCopy()
+	app.Spec.Identity = nil
+
+	for _, test := range []struct {
+		name           string
+		project        string
+		expectProvider deploymentv1alpha1.IdentityProvider
+	}{
+		{"no project", "", deploymentv1alpha1.IdentityProviderNone},
+		{"legacy project", "legacy", deploymentv1alpha1.IdentityProviderGKE},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			Project = test.project
+
+			received := identity(app, deploymentv1alpha1.ClusterBot)
+			if test.expectProvider != received.Provider {
+				t.Errorf("expected %q, received %q", test.expectProvider, received.Provider)
+			}
+
+			if len(received.ImagePullSecrets) != 1 || received.ImagePullSecrets[0].Name != "ecr-pull" {
+				t.Errorf("expected the legacy pull secret, received %#v", received.ImagePullSecrets)
+			}
+		})
+	}
+
+	t.Run("spec wins", func(t *testing.T) {
+		Project = "legacy"
+
+		received := identity(bot, deploymentv1alpha1.ClusterBot)
+		if received.GKE.Project != "testing" {
+			t.Errorf("expected %q, received %q", "testing", received.GKE.Project)
+		}
+	})
+}
+
+func TestPodLabels_Azure(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Spec.Identity = &deploymentv1alpha1.CloudIdentity{
+		Provider: deploymentv1alpha1.IdentityProviderAzure,
+		Azure:    &deploymentv1alpha1.AzureIdentity{ClientID: "abc"},
+	}
+
+	d := mustDeployment(t, app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app))
+
+	if d.Spec.Template.Labels["azure.workload.identity/use"] != "true" {
+		t.Errorf("expected azure workload identity label, received %#v", d.Spec.Template.Labels)
+	}
+
+	if _, ok := d.Spec.Selector.MatchLabels["azure.workload.identity/use"]; ok {
+		t.Errorf("unexpected azure workload identity selector")
+	}
+
+	if _, ok := GecBotLabels(app)["azure.workload.identity/use"]; ok {
+		t.Errorf("pod labels leaked into app labels")
+	}
+}
+
+func TestIdentity_PerApp(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Spec.Processor.Identity = &deploymentv1alpha1.CloudIdentity{
+		Provider: deploymentv1alpha1.IdentityProviderNone,
+	}
+	app.Spec.Slacker.Identity = &deploymentv1alpha1.CloudIdentity{
+		Provider: deploymentv1alpha1.IdentityProviderGKE,
+		GKE: &deploymentv1alpha1.GKEIdentity{
+			Project:        "testing",
+			ServiceAccount: "gec-slacker-secrets",
+		},
+	}
+
+	annotations := make(map[string]string)
+	for _, test := range []struct {
+		ca               deploymentv1alpha1.ClusterApp
+		labels           map[string]string
+		expectAnnotation string
+	}{
+		{deploymentv1alpha1.ClusterBot, GecBotLabels(app), "my-test-cluster@testing.iam.gserviceaccount.com"},
+		{deploymentv1alpha1.ClusterProcessor, GecProcessorLabels(app), ""},
+		{deploymentv1alpha1.ClusterSlacker, GecSlackerLabels(app), "gec-slacker-secrets@testing.iam.gserviceaccount.com"},
+	} {
+		t.Run(test.ca.String(), func(t *testing.T) {
+			sa := serviceAccount(app, test.ca, test.labels)
+
+			received := sa.Annotations["iam.gke.io/gcp-service-account"]
+			if test.expectAnnotation != received {
+				t.Errorf("expected %q, received %q", test.expectAnnotation, received)
+			}
+
+			for other, a := range annotations {
+				if a == received {
+					t.Errorf("%s shares an identity with %s", test.ca, other)
+				}
+			}
+
+			annotations[test.ca.String()] = received
+
+			if len(sa.ImagePullSecrets) != 1 || sa.ImagePullSecrets[0].Name != "ecr-pull" {
+				t.Errorf("expected cluster image pull secrets, received %#v", sa.ImagePullSecrets)
+			}
+		})
+	}
+}
+
+func TestServiceAccount_Annotations(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		existing     map[string]string
+		expect       map[string]string
+		expectUpdate bool
+	}{
+		{"unchanged", map[string]string{"iam.gke.io/gcp-service-account": "my-test-cluster@testing.iam.gserviceaccount.com"}, nil, false},
+		{"foreign annotation", map[string]string{
+			"iam.gke.io/gcp-service-account": "my-test-cluster@testing.iam.gserviceaccount.com",
+			"example.com/scanned":            "true",
+		}, nil, false},
+		{"stale identity", map[string]string{
+			"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/gec",
+			"example.com/scanned":        "true",
+		}, map[string]string{
+			"iam.gke.io/gcp-service-account": "my-test-cluster@testing.iam.gserviceaccount.com",
+			"example.com/scanned":            "true",
+		}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			app := bot.DeepCopy()
+
+			existing := serviceAccount(app, deploymentv1alpha1.ClusterBot, GecBotLabels(app))
+			existing.Annotations = test.existing
+
+			c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app, existing).Build()
+
+			requeue, err := ServiceAccount(context.Background(), c, testScheme(t), app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app))
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if test.expectUpdate != (requeue > 0) {
+				t.Errorf("expected update %v, received requeue %s", test.expectUpdate, requeue)
+			}
+
+			received := new(corev1.ServiceAccount)
+
+			err = c.Get(context.Background(), client.ObjectKeyFromObject(existing), received)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			expect := test.expect
+			if expect == nil {
+				expect = test.existing
+			}
+
+			if !reflect.DeepEqual(expect, received.Annotations) {
+				t.Errorf("expected %#v, received %#v", expect, received.Annotations)
+			}
+		})
+	}
+}
+
+func TestDeployment_TemplateAnnotations(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		mutate        func(map[string]string)
+		expectRollout bool
+	}{
+		{"unchanged", func(map[string]string) {}, false},
+		{"restarted", func(a map[string]string) {
+			a["kubectl.kubernetes.io/restartedAt"] = "2026-10-19T09:00:00Z"
+		}, false},
+		{"stale checksum", func(a map[string]string) {
+			a["kubectl.kubernetes.io/restartedAt"] = "2026-10-19T09:00:00Z"
+			a[configChecksumAnnotation] = "stale"
+		}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			app := bot.DeepCopy()
+			ctx := context.WithValue(context.Background(), "config", map[string]string{})
+
+			c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+
+			_, err := Deployment(ctx, c, testScheme(t), app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app))
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			existing := new(appsv1.Deployment)
+
+			err = c.Get(ctx, client.ObjectKey{Name: app.InClusterName(deploymentv1alpha1.ClusterBot), Namespace: app.Namespace}, existing)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			checksum := existing.Spec.Template.Annotations[configChecksumAnnotation]
+			test.mutate(existing.Spec.Template.Annotations)
+
+			err = c.Update(ctx, existing)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			requeue, err := Deployment(ctx, c, testScheme(t), app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app))
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if test.expectRollout != (requeue > 0) {
+				t.Errorf("expected rollout %v, received requeue %s", test.expectRollout, requeue)
+			}
+
+			received := new(appsv1.Deployment)
+
+			err = c.Get(ctx, client.ObjectKeyFromObject(existing), received)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			expect := existing.Spec.Template.Annotations
+			expect[configChecksumAnnotation] = checksum
+
+			if !reflect.DeepEqual(expect, received.Spec.Template.Annotations) {
+				t.Errorf("expected %v, received %v", expect, received.Spec.Template.Annotations)
+			}
+		})
+	}
+}
+
+func TestDeployment_IdentityLabels(t *testing.T) {
+	azure := &deploymentv1alpha1.CloudIdentity{
+		Provider: deploymentv1alpha1.IdentityProviderAzure,
+		Azure:    &deploymentv1alpha1.AzureIdentity{ClientID: "abc"},
+	}
+	none := &deploymentv1alpha1.CloudIdentity{
+		Provider: deploymentv1alpha1.IdentityProviderNone,
+	}
+
+	for _, test := range []struct {
+		name        string
+		from, to    *deploymentv1alpha1.CloudIdentity
+		expectLabel bool
+	}{
+		{"to azure", none, azure, true},
+		{"from azure", azure, none, false},
+		{"unchanged", azure, azure, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			app := bot.DeepCopy()
+			app.Spec.Identity = test.from
+
+			ctx := context.WithValue(context.Background(), "config", map[string]string{})
+			c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+
+			_, err := Deployment(ctx, c, testScheme(t), app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app))
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			existing := new(appsv1.Deployment)
+
+			err = c.Get(ctx, client.ObjectKey{Name: app.InClusterName(deploymentv1alpha1.ClusterBot), Namespace: app.Namespace}, existing)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			existing.Spec.Template.Labels["example.com/team"] = "safeguarding"
+
+			err = c.Update(ctx, existing)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			app.Spec.Identity = test.to
+
+			requeue, err := Deployment(ctx, c, testScheme(t), app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app))
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			expectRollout := test.from != test.to
+			if expectRollout != (requeue > 0) {
+				t.Errorf("expected rollout %v, received requeue %s", expectRollout, requeue)
+			}
+
+			received := new(appsv1.Deployment)
+
+			err = c.Get(ctx, client.ObjectKeyFromObject(existing), received)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if _, ok := received.Spec.Template.Labels["azure.workload.identity/use"]; test.expectLabel != ok {
+				t.Errorf("expected azure workload identity label %v, received %#v", test.expectLabel, received.Spec.Template.Labels)
+			}
+
+			if received.Spec.Template.Labels["example.com/team"] != "safeguarding" {
+				t.Errorf("expected foreign label to be kept, received %#v", received.Spec.Template.Labels)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// component is what the operator needs to reconcile a ClusterApp; the
+// upserters to run, and how to label and configure what they create
+type component struct {
+	upserters []upserter
+	selectors func(*appv1alpha1.Cluster) map[string]string
+	labels    func(*appv1alpha1.Cluster) map[string]string
+	config    func(*appv1alpha1.Cluster) map[string]string
+}
+
+var builtinComponents = map[appv1alpha1.ClusterApp]component{
+	appv1alpha1.ClusterBot:       {gecBotUpserters, GecBotSelectors, GecBotLabels, GecBotConfig},
+	appv1alpha1.ClusterProcessor: {gecProcessorUpserters, GecProcessorSelectors, GecProcessorLabels, GecProcessorConfig},
+	appv1alpha1.ClusterSlacker:   {gecSlackerUpserters, GecSlackerSelectors, GecSlackerLabels, GecSlackerConfig},
+}
+
+var componentUpserters = []upserter{
+	ServiceAccount,
+	ConfigMap,
+	PVC,
+	Deployment,
+	PodDisruptionBudget,
+	Service,
+	NetworkPolicy,
+}
+
+// componentFor returns how to reconcile a ClusterApp; either a built-in
+// app, or a component from a Cluster's spec
+func componentFor(ca appv1alpha1.ClusterApp) (component, bool) {
+	if c, ok := builtinComponents[ca]; ok {
+		return c, true
+	}
+
+	if !ca.Generic() {
+		return component{}, false
+	}
+
+	return component{
+		upserters: componentUpserters,
+		selectors: func(app *appv1alpha1.Cluster) map[string]string { return ComponentSelectors(app, ca) },
+		labels:    func(app *appv1alpha1.Cluster) map[string]string { return ComponentLabels(app, ca) },
+		config:    func(app *appv1alpha1.Cluster) map[string]string { return ComponentConfig(app, ca) },
+	}, true
+}
+
+func ComponentSelectors(app *appv1alpha1.Cluster, ca appv1alpha1.ClusterApp) map[string]string {
+	return map[string]string{
+		"cluster": app.Name,
+		"app":     ca.String(),
+	}
+}
+
+func ComponentLabels(app *appv1alpha1.Cluster, ca appv1alpha1.ClusterApp) map[string]string {
+	l := ComponentSelectors(app, ca)
+	l["version"] = app.InClusterApp(ca).Version
+
+	return l
+}
+
+// ComponentConfig returns a component's own config, plus where to find
+// redis
+func ComponentConfig(app *appv1alpha1.Cluster, ca appv1alpha1.ClusterApp) map[string]string {
+	config := map[string]string{
+		"REDIS_ADDR": app.RedisAddr(),
+	}
+
+	for _, comp := range app.Spec.Components {
+		if comp.Name != ca.String() {
+			continue
+		}
+
+		for k, v := range comp.Config {
+			config[k] = v
+		}
+	}
+
+	return config
+}
+
+// PruneComponents deletes the objects of components which have been
+// removed from a Cluster's spec. Only objects the Cluster controls, and
+// whose app label names a component rather than a built-in app, are
+// deleted
+func PruneComponents(ctx context.Context, c client.Client, s *runtime.Scheme, app *appv1alpha1.Cluster, ca appv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
+	apps, err := app.Apps()
+	if err != nil {
+		return
+	}
+
+	current := make(map[appv1alpha1.ClusterApp]bool, len(apps))
+	for _, ca := range apps {
+		current[ca] = true
+	}
+
+	// Deployments go first, so that pods don't outlive their
+	// ServiceAccounts and ConfigMaps
+	for _, list := range []client.ObjectList{
+		new(appsv1.DeploymentList),
+		new(policyv1.PodDisruptionBudgetList),
+		new(corev1.ServiceList),
+		new(networkingv1.NetworkPolicyList),
+		new(corev1.ConfigMapList),
+		new(corev1.ServiceAccountList),
+	} {
+		err = c.List(ctx, list, client.InNamespace(app.Namespace), client.MatchingLabels{"cluster": app.Name})
+		if err != nil {
+			return
+		}
+
+		err = meta.EachListItem(list, func(o runtime.Object) error {
+			obj := o.(client.Object)
+
+			owner := appv1alpha1.ClusterApp(obj.GetLabels()["app"])
+			if !owner.Generic() || current[owner] || !metav1.IsControlledBy(obj, app) {
+				return nil
+			}
+
+			return client.IgnoreNotFound(c.Delete(ctx, obj))
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var translator = func() *deploymentv1alpha1.Cluster {
+	app := processor.DeepCopy()
+	app.Spec.Components = []deploymentv1alpha1.Component{
+		{
+			Name:   "gec-translator",
+			App:    deploymentv1alpha1.App{Version: "v0.0.4"},
+			Image:  "registry.example.com/gec/translator",
+			Config: map[string]string{"MODEL": "small"},
+		},
+	}
+
+	return app
+}()
+
+func translatorApp(t *testing.T) deploymentv1alpha1.ClusterApp {
+	t.Helper()
+
+	apps, err := translator.Apps()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return apps[len(apps)-1]
+}
+
+func TestComponent_Deployment(t *testing.T) {
+	ca := translatorApp(t)
+
+	expect := new(appsv1.Deployment)
+
+	err := unmarshalFile("testdata/component-deployment.yaml", expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := mustDeployment(t, translator, ca, ComponentLabels(translator, ca), ComponentSelectors(translator, ca))
+	received.Spec.Template.Spec.SecurityContext = nil
+
+	if !cmp.Equal(expect.Spec, received.Spec) {
+		t.Fatal(cmp.Diff(expect, received))
+	}
+}
+
+func TestComponent_Database(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		database  bool
+		resources corev1.ResourceList
+		expectCPU string
+	}{
+		{"defaults", false, nil, "100m"},
+		{"own resources and database", true, corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}, "500m"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			app := translator.DeepCopy()
+			app.Spec.Components[0].Database = test.database
+			app.Spec.Components[0].Resources = test.resources
+
+			ca := translatorApp(t)
+			d := mustDeployment(t, app, ca, ComponentLabels(app, ca), ComponentSelectors(app, ca))
+
+			if received := d.Spec.Template.Spec.Containers[0].Resources.Limits.Cpu().String(); test.expectCPU != received {
+				t.Errorf("expected %q, received %q", test.expectCPU, received)
+			}
+
+			c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+
+			_, err := PVC(context.Background(), c, testScheme(t), app, ca, ComponentLabels(app, ca), ComponentSelectors(app, ca))
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			err = c.Get(context.Background(), types.NamespacedName{Name: app.InClusterName(ca), Namespace: app.Namespace}, new(corev1.PersistentVolumeClaim))
+			if test.database != (err == nil) {
+				t.Errorf("expected volume claim %v, received %v", test.database, err)
+			}
+
+			var mounted bool
+			for _, m := range d.Spec.Template.Spec.Containers[0].VolumeMounts {
+				mounted = mounted || m.MountPath == "/database/"
+			}
+
+			if test.database != mounted {
+				t.Errorf("expected database mount %v, received %v", test.database, mounted)
+			}
+		})
+	}
+}
+
+func TestComponent_NetworkPolicy(t *testing.T) {
+	ca := translatorApp(t)
+
+	expect := new(networkingv1.NetworkPolicy)
+
+	err := unmarshalFile("testdata/component-netpol.yaml", expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := networkPolicy(translator, ca, ComponentLabels(translator, ca), ComponentSelectors(translator, ca), nil)
+	if !cmp.Equal(expect, received) {
+		t.Fatal(cmp.Diff(expect, received))
+	}
+}
+
+func TestComponentConfig(t *testing.T) {
+	expect := map[string]string{
+		"REDIS_ADDR": "redis.example.com:6379",
+		"MODEL":      "small",
+	}
+
+	received := ComponentConfig(translator, translatorApp(t))
+	if !reflect.DeepEqual(expect, received) {
+		t.Errorf("expected %#v, received %#v", expect, received)
+	}
+}
+
+func TestComponentFor(t *testing.T) {
+	ca := translatorApp(t)
+
+	for _, test := range []struct {
+		ca              deploymentv1alpha1.ClusterApp
+		expectOK        bool
+		expectSelectors map[string]string
+	}{
+		{deploymentv1alpha1.ClusterBot, true, GecBotSelectors(translator)},
+		{ca, true, map[string]string{"cluster": "my-test-cluster", "app": "gec-translator"}},
+		{deploymentv1alpha1.ClusterMeta, false, nil},
+		{deploymentv1alpha1.UnknownClusterApp, false, nil},
+	} {
+		t.Run(test.ca.String(), func(t *testing.T) {
+			c, ok := componentFor(test.ca)
+			if test.expectOK != ok {
+				t.Fatalf("expected %v, received %v", test.expectOK, ok)
+			}
+
+			if !ok {
+				return
+			}
+
+			if received := c.selectors(translator); !reflect.DeepEqual(test.expectSelectors, received) {
+				t.Errorf("expected %#v, received %#v", test.expectSelectors, received)
+			}
+
+			if received := appFromLabels(c.labels(translator)); test.ca != received {
+				t.Errorf("expected labels to map back to %s, received %s", test.ca, received)
+			}
+		})
+	}
+}
+
+func TestReconcile_Components(t *testing.T) {
+	app := translator.DeepCopy()
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	r := &ClusterReconciler{Client: c, Scheme: testScheme(t)}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: app.Name, Namespace: app.Namespace}}
+
+	var err error
+	for i := 0; i < 5; i++ {
+		_, err = r.Reconcile(context.Background(), req)
+	}
+
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	name := types.NamespacedName{Name: "my-test-cluster-gec-translator", Namespace: app.Namespace}
+
+	d := new(appsv1.Deployment)
+
+	err = c.Get(context.Background(), name, d)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if received := d.Spec.Template.Spec.Containers[0].Image; received != "registry.example.com/gec/translator:v0.0.4" {
+		t.Errorf("unexpected image %q", received)
+	}
+
+	cm := new(corev1.ConfigMap)
+
+	err = c.Get(context.Background(), name, cm)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if cm.Data["MODEL"] != "small" {
+		t.Errorf("expected component config, received %#v", cm.Data)
+	}
+
+	err = c.Get(context.Background(), req.NamespacedName, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if len(app.Status.Components) != 1 || app.Status.Components[0].Name != "gec-translator" {
+		t.Errorf("expected status for gec-translator, received %#v", app.Status.Components)
+	}
+
+	t.Run("removed", func(t *testing.T) {
+		app.Spec.Components = nil
+
+		err = c.Update(context.Background(), app)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		_, err = r.Reconcile(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		for _, obj := range []client.Object{
+			new(appsv1.Deployment),
+			new(corev1.ConfigMap),
+			new(corev1.ServiceAccount),
+			new(networkingv1.NetworkPolicy),
+			new(policyv1.PodDisruptionBudget),
+		} {
+			err = c.Get(context.Background(), name, obj)
+			if !errors.IsNotFound(err) {
+				t.Errorf("expected %T to be pruned, received %#v", obj, err)
+			}
+		}
+
+		err = c.Get(context.Background(), types.NamespacedName{Name: "my-test-cluster-gec-processor", Namespace: app.Namespace}, new(appsv1.Deployment))
+		if err != nil {
+			t.Errorf("expected built-in apps to be kept, received %#v", err)
+		}
+	})
+}
+
+func TestPruneComponents(t *testing.T) {
+	app := translator.DeepCopy()
+	app.UID = "cluster-uid"
+
+	owned := func(name, component string, controlled bool) *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		d.Name = name
+		d.Namespace = app.Namespace
+		d.Labels = map[string]string{"cluster": app.Name, "app": component}
+
+		if controlled {
+			err := ctrl.SetControllerReference(app, d, testScheme(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return d
+	}
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(
+		owned("translator", "gec-translator", true),
+		owned("archiver", "gec-archiver", true),
+		owned("bot", "gec-bot", true),
+		owned("foreign", "gec-archiver", false),
+	).Build()
+
+	_, err := PruneComponents(context.Background(), c, testScheme(t), app, deploymentv1alpha1.ClusterMeta, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	for name, expectKept := range map[string]bool{
+		"translator": true,
+		"archiver":   false,
+		"bot":        true,
+		"foreign":    true,
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: app.Namespace}, new(appsv1.Deployment))
+			if kept := err == nil; kept != expectKept {
+				t.Errorf("expected kept %v, received error %v", expectKept, err)
+			}
+		})
+	}
+}
+
+func TestReconcile_ReservedComponent(t *testing.T) {
+	app := processor.DeepCopy()
+	app.Spec.Components = []deploymentv1alpha1.Component{
+		{Name: "gec-bot", App: deploymentv1alpha1.App{Version: "v0.0.1"}, Image: "gec-bot"},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	r := &ClusterReconciler{Client: c, Scheme: testScheme(t)}
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: app.Name, Namespace: app.Namespace}})
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// containerSecurityContext enforces the operator's security context on
+// top of whatever a container sets itself.
+//
+// Containers can never run privileged or as root, gain capabilities, or
+// write to their root filesystem; those which need scratch space should
+// mount an emptyDir. They may pick a localhost seccomp profile, but
+// otherwise get the same as the app's own container
+func containerSecurityContext(sc *corev1.SecurityContext) *corev1.SecurityContext {
+	var (
+		trueVal  = true
+		falseVal = false
+	)
+
+	if sc == nil {
+		sc = new(corev1.SecurityContext)
+	} else {
+		sc = sc.DeepCopy()
+	}
+
+	sc.Privileged = &falseVal
+	sc.AllowPrivilegeEscalation = &falseVal
+	sc.RunAsNonRoot = &trueVal
+	sc.Capabilities = &corev1.Capabilities{
+		Drop: []corev1.Capability{"ALL"},
+	}
+
+	sc.ReadOnlyRootFilesystem = &trueVal
+
+	if sc.SeccompProfile == nil || sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		sc.SeccompProfile = &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		}
+	}
+
+	return sc
+}
+
+// extraContainers returns an app's extra or init containers with the
+// operator's security context enforced, and the fields the API server
+// would otherwise default filled in, so that comparisons against the
+// live Deployment hold
+func extraContainers(containers []corev1.Container) []corev1.Container {
+	if len(containers) == 0 {
+		return nil
+	}
+
+	out := make([]corev1.Container, len(containers))
+	for i, c := range containers {
+		c = *c.DeepCopy()
+
+		c.SecurityContext = containerSecurityContext(c.SecurityContext)
+		c.Ports = containerPorts(c.Ports)
+		c.Env = extraEnv(c.Env)
+		c.LivenessProbe = deploymentv1alpha1.DefaultProbe(c.LivenessProbe)
+		c.ReadinessProbe = deploymentv1alpha1.DefaultProbe(c.ReadinessProbe)
+		c.StartupProbe = deploymentv1alpha1.DefaultProbe(c.StartupProbe)
+
+		if c.TerminationMessagePath == "" {
+			c.TerminationMessagePath = corev1.TerminationMessagePathDefault
+		}
+
+		if c.TerminationMessagePolicy == "" {
+			c.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+		}
+
+		if c.ImagePullPolicy == "" {
+			c.ImagePullPolicy = pullPolicy(c.Image)
+		}
+
+		out[i] = c
+	}
+
+	return out
+}
+
+// pullPolicy mirrors the API server's default; images tagged latest, or
+// not tagged at all, are always pulled
+func pullPolicy(image string) corev1.PullPolicy {
+	if strings.Contains(image, "@") {
+		return corev1.PullIfNotPresent
+	}
+
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") || image[i+1:] == "latest" {
+		return corev1.PullAlways
+	}
+
+	return corev1.PullIfNotPresent
+}
+
+// extraEnv fills in the API version the API server would otherwise
+// default on field references
+func extraEnv(env []corev1.EnvVar) []corev1.EnvVar {
+	if len(env) == 0 {
+		return nil
+	}
+
+	out := make([]corev1.EnvVar, len(env))
+	for i, e := range env {
+		out[i] = *e.DeepCopy()
+
+		if out[i].ValueFrom != nil && out[i].ValueFrom.FieldRef != nil && out[i].ValueFrom.FieldRef.APIVersion == "" {
+			out[i].ValueFrom.FieldRef.APIVersion = "v1"
+		}
+	}
+
+	return out
+}
+
+// extraVolumes fills in the modes the API server would otherwise default
+// on an app's extra volumes. hostPath volumes would give apps the node's
+// filesystem, and so aren't allowed
+func extraVolumes(volumes []corev1.Volume) ([]corev1.Volume, error) {
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+
+	out := make([]corev1.Volume, len(volumes))
+	for i, v := range volumes {
+		v = *v.DeepCopy()
+
+		switch {
+		case v.ConfigMap != nil:
+			v.ConfigMap.DefaultMode = defaultMode(v.ConfigMap.DefaultMode)
+
+		case v.Secret != nil:
+			v.Secret.DefaultMode = defaultMode(v.Secret.DefaultMode)
+
+		case v.Projected != nil:
+			v.Projected.DefaultMode = defaultMode(v.Projected.DefaultMode)
+
+		case v.DownwardAPI != nil:
+			v.DownwardAPI.DefaultMode = defaultMode(v.DownwardAPI.DefaultMode)
+
+			for j := range v.DownwardAPI.Items {
+				if ref := v.DownwardAPI.Items[j].FieldRef; ref != nil && ref.APIVersion == "" {
+					ref.APIVersion = "v1"
+				}
+			}
+
+		case v.HostPath != nil:
+			return nil, fmt.Errorf("extra volume %q: hostPath volumes aren't allowed", v.Name)
+		}
+
+		out[i] = v
+	}
+
+	return out, nil
+}
+
+func defaultMode(m *int32) *int32 {
+	if m != nil {
+		return m
+	}
+
+	mode := corev1.ConfigMapVolumeSourceDefaultMode
+
+	return &mode
+}
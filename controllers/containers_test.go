@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestContainerSecurityContext(t *testing.T) {
+	var (
+		trueVal  = true
+		falseVal = false
+		uid      = int64(1000)
+	)
+
+	for _, test := range []struct {
+		name          string
+		in            *corev1.SecurityContext
+		expectSeccomp corev1.SeccompProfileType
+	}{
+		{"unset", nil, corev1.SeccompProfileTypeRuntimeDefault},
+		{"privileged", &corev1.SecurityContext{
+			Privileged:               &trueVal,
+			AllowPrivilegeEscalation: &trueVal,
+			RunAsNonRoot:             &falseVal,
+			Capabilities:             &corev1.Capabilities{Add: []corev1.Capability{"NET_ADMIN"}},
+		}, corev1.SeccompProfileTypeRuntimeDefault},
+		{"writable root filesystem", &corev1.SecurityContext{ReadOnlyRootFilesystem: &falseVal}, corev1.SeccompProfileTypeRuntimeDefault},
+		{"unconfined", &corev1.SecurityContext{
+			SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined},
+		}, corev1.SeccompProfileTypeRuntimeDefault},
+		{"localhost profile", &corev1.SecurityContext{
+			SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost},
+		}, corev1.SeccompProfileTypeLocalhost},
+		{"run as user", &corev1.SecurityContext{RunAsUser: &uid}, corev1.SeccompProfileTypeRuntimeDefault},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := containerSecurityContext(test.in)
+
+			if *received.Privileged || *received.AllowPrivilegeEscalation || !*received.RunAsNonRoot {
+				t.Errorf("expected enforced defaults, received %#v", received)
+			}
+
+			if !reflect.DeepEqual(&corev1.Capabilities{Drop: []corev1.Capability{"ALL"}}, received.Capabilities) {
+				t.Errorf("expected all capabilities dropped, received %#v", received.Capabilities)
+			}
+
+			if !*received.ReadOnlyRootFilesystem {
+				t.Errorf("expected a read-only root filesystem")
+			}
+
+			if test.expectSeccomp != received.SeccompProfile.Type {
+				t.Errorf("expected %q, received %q", test.expectSeccomp, received.SeccompProfile.Type)
+			}
+
+			if test.in != nil && !reflect.DeepEqual(test.in.RunAsUser, received.RunAsUser) {
+				t.Errorf("expected RunAsUser to be kept, received %v", received.RunAsUser)
+			}
+		})
+	}
+}
+
+func TestPullPolicy(t *testing.T) {
+	for _, test := range []struct {
+		image  string
+		expect corev1.PullPolicy
+	}{
+		{"fluent-bit", corev1.PullAlways},
+		{"fluent-bit:latest", corev1.PullAlways},
+		{"fluent-bit:2.0.5", corev1.PullIfNotPresent},
+		{"localhost:5000/fluent-bit", corev1.PullAlways},
+		{"localhost:5000/fluent-bit:2.0.5", corev1.PullIfNotPresent},
+		{"fluent-bit@sha256:abc", corev1.PullIfNotPresent},
+	} {
+		t.Run(test.image, func(t *testing.T) {
+			received := pullPolicy(test.image)
+			if test.expect != received {
+				t.Errorf("expected %q, received %q", test.expect, received)
+			}
+		})
+	}
+}
+
+func TestBot_DeploymentExtras(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Spec.Bot.ExtraEnv = []corev1.EnvVar{
+		{Name: "LOG_FORMAT", Value: "json"},
+		{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+	}
+	app.Spec.Bot.ExtraVolumes = []corev1.Volume{
+		{Name: "logs", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		{Name: "fluent-bit", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "fluent-bit"},
+		}}},
+	}
+	app.Spec.Bot.ExtraVolumeMounts = []corev1.VolumeMount{
+		{Name: "logs", MountPath: "/var/log/gec"},
+	}
+	app.Spec.Bot.ExtraContainers = []corev1.Container{
+		{
+			Name:  "fluent-bit",
+			Image: "fluent/fluent-bit:2.0.5",
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: "logs", MountPath: "/var/log/gec", ReadOnly: true},
+				{Name: "fluent-bit", MountPath: "/fluent-bit/etc/"},
+			},
+		},
+	}
+	app.Spec.Bot.InitContainers = []corev1.Container{
+		{
+			Name:    "migrate",
+			Image:   app.InClusterImage(deploymentv1alpha1.BuiltinDefaults(), deploymentv1alpha1.ClusterBot),
+			Command: []string{"/gec-bot", "migrate"},
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: app.InClusterName(deploymentv1alpha1.ClusterBot), MountPath: "/database/"},
+			},
+		},
+	}
+
+	expect := new(appsv1.Deployment)
+
+	err := unmarshalFile("testdata/bot-deployment-extras.yaml", expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := mustDeployment(t, app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app))
+	received.Spec.Template.Spec.SecurityContext = nil
+
+	if !cmp.Equal(expect.Spec, received.Spec) {
+		t.Fatal(cmp.Diff(expect.Spec, received.Spec))
+	}
+}
+
+func TestExtraVolumes_HostPath(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Spec.Bot.ExtraVolumes = []corev1.Volume{
+		{Name: "docker", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"}}},
+	}
+
+	_, err := deployment(deploymentv1alpha1.BuiltinDefaults(), app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app))
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"time"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	credentialsVolume = "credentials"
+
+	// credentialsChecksumAnnotation is stamped onto pod templates so
+	// that changes to referenced Secrets roll an app's pods
+	credentialsChecksumAnnotation = "app.gec/credentials-checksum"
+)
+
+// Credentials ensures every Secret key an app's credentials reference
+// exists, erroring (and so requeueing) where one doesn't
+func Credentials(ctx context.Context, c client.Client, s *runtime.Scheme, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
+	_, err = credentialsChecksum(ctx, c, app, ca)
+
+	return
+}
+
+// credentialsChecksum validates an app's credentials and returns a
+// checksum of the referenced values, or an empty string where the app
+// has no credentials
+func credentialsChecksum(ctx context.Context, c client.Client, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp) (sum string, err error) {
+	creds := app.InClusterCredentials(ca)
+	if len(creds) == 0 {
+		return
+	}
+
+	h := sha256.New()
+	secrets := make(map[string]*corev1.Secret)
+
+	for _, cred := range creds {
+		secret, ok := secrets[cred.Ref.Name]
+		if !ok {
+			secret = new(corev1.Secret)
+
+			err = c.Get(ctx, types.NamespacedName{Name: cred.Ref.Name, Namespace: app.Namespace}, secret)
+			if err != nil {
+				if errors.IsNotFound(err) {
+					err = fmt.Errorf("%s: credentials secret %q not found", ca, cred.Ref.Name)
+				}
+
+				return
+			}
+
+			secrets[cred.Ref.Name] = secret
+		}
+
+		v, ok := secret.Data[cred.Ref.Key]
+		if !ok || len(v) == 0 {
+			err = fmt.Errorf("%s: credentials secret %q has no key %q", ca, cred.Ref.Name, cred.Ref.Key)
+
+			return
+		}
+
+		fmt.Fprintf(h, "%s/%s=", cred.Ref.Name, cred.Ref.Key)
+		h.Write(v)
+		h.Write([]byte{0})
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// credentialsEnv returns the environment variables through which an app
+// receives its credentials
+func credentialsEnv(creds []deploymentv1alpha1.Credential) (env []corev1.EnvVar) {
+	for _, cred := range creds {
+		switch cred.MountAs {
+		case deploymentv1alpha1.CredentialMountFile:
+			env = append(env, corev1.EnvVar{
+				Name:  cred.EnvName + "_FILE",
+				Value: cred.Path(),
+			})
+
+		default:
+			env = append(env, corev1.EnvVar{
+				Name: cred.EnvName,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: cred.Ref.Name,
+						},
+						Key: cred.Ref.Key,
+					},
+				},
+			})
+		}
+	}
+
+	return
+}
+
+// credentialsVolumes returns a single projected volume containing every
+// credential an app receives as a file, along with its mount
+func credentialsVolumes(creds []deploymentv1alpha1.Credential) ([]corev1.Volume, []corev1.VolumeMount) {
+	var (
+		defaultMode int32 = 0444
+		sources           = make([]corev1.VolumeProjection, 0)
+		bySecret          = make(map[string][]corev1.KeyToPath)
+		names             = make([]string, 0)
+	)
+
+	for _, cred := range creds {
+		if cred.MountAs != deploymentv1alpha1.CredentialMountFile {
+			continue
+		}
+
+		if _, ok := bySecret[cred.Ref.Name]; !ok {
+			names = append(names, cred.Ref.Name)
+		}
+
+		bySecret[cred.Ref.Name] = append(bySecret[cred.Ref.Name], corev1.KeyToPath{
+			Key:  cred.Ref.Key,
+			Path: cred.FileName,
+		})
+	}
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		sources = append(sources, corev1.VolumeProjection{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: name},
+				Items:                bySecret[name],
+			},
+		})
+	}
+
+	return []corev1.Volume{{
+		Name: credentialsVolume,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources:     sources,
+				DefaultMode: &defaultMode,
+			},
+		},
+	}}, []corev1.VolumeMount{{
+		Name:      credentialsVolume,
+		MountPath: deploymentv1alpha1.CredentialsPath,
+		ReadOnly:  true,
+	}}
+}
+
+// secretToClusters maps a Secret to the Clusters whose app or registry
+// credentials reference it, or whose apps it overrides
+func (r *ClusterReconciler) secretToClusters(o client.Object) (requests []reconcile.Request) {
+	clusters := new(deploymentv1alpha1.ClusterList)
+
+	err := r.List(context.Background(), clusters, client.InNamespace(o.GetNamespace()))
+	if err != nil {
+		return
+	}
+
+	for _, cluster := range clusters.Items {
+		if referencesSecret(&cluster, o.GetName()) || isOverride(&cluster, o.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace},
+			})
+		}
+	}
+
+	return
+}
+
+func referencesSecret(app *deploymentv1alpha1.Cluster, name string) bool {
+	if reg := app.Spec.Registry; reg != nil && reg.SecretRef != nil && reg.SecretRef.Name == name {
+		return true
+	}
+
+	apps, _ := app.Apps()
+
+	for _, ca := range apps {
+		for _, cred := range app.InClusterCredentials(ca) {
+			if cred.Ref.Name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func credentialledCluster() *deploymentv1alpha1.Cluster {
+	app := bot.DeepCopy()
+	app.Spec.Bot.Credentials = &deploymentv1alpha1.WhatsAppCredentials{
+		Session: deploymentv1alpha1.SecretKeySelector{Name: "whatsapp", Key: "session"},
+	}
+	app.Spec.Slacker.Credentials = &deploymentv1alpha1.SlackCredentials{
+		BotToken:      deploymentv1alpha1.SecretKeySelector{Name: "slack", Key: "token"},
+		SigningSecret: deploymentv1alpha1.SecretKeySelector{Name: "slack", Key: "signing-secret"},
+	}
+
+	return app
+}
+
+func testSecret(name string, data map[string]string) *corev1.Secret {
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "testing",
+		},
+		Data: make(map[string][]byte),
+	}
+
+	for k, v := range data {
+		s.Data[k] = []byte(v)
+	}
+
+	return s
+}
+
+func TestCredentialsChecksum(t *testing.T) {
+	app := credentialledCluster()
+
+	for _, test := range []struct {
+		name        string
+		ca          deploymentv1alpha1.ClusterApp
+		secrets     []*corev1.Secret
+		expectErr   bool
+		expectEmpty bool
+	}{
+		{"no credentials", deploymentv1alpha1.ClusterProcessor, nil, false, true},
+		{"missing secret", deploymentv1alpha1.ClusterSlacker, nil, true, true},
+		{"missing key", deploymentv1alpha1.ClusterSlacker, []*corev1.Secret{testSecret("slack", map[string]string{"token": "xoxb"})}, true, true},
+		{"empty key", deploymentv1alpha1.ClusterBot, []*corev1.Secret{testSecret("whatsapp", map[string]string{"session": ""})}, true, true},
+		{"valid", deploymentv1alpha1.ClusterSlacker, []*corev1.Secret{testSecret("slack", map[string]string{"token": "xoxb", "signing-secret": "abc"})}, false, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			b := fake.NewClientBuilder().WithScheme(testScheme(t))
+			for _, s := range test.secrets {
+				b = b.WithObjects(s)
+			}
+
+			received, err := credentialsChecksum(context.Background(), b.Build(), app, test.ca)
+			if err == nil && test.expectErr {
+				t.Errorf("expected error")
+			} else if err != nil && !test.expectErr {
+				t.Errorf("unexpected error: %#v", err)
+			}
+
+			if test.expectEmpty != (received == "") {
+				t.Errorf("unexpected checksum %q", received)
+			}
+		})
+	}
+}
+
+func TestCredentialsChecksum_ChangesWithSecret(t *testing.T) {
+	app := credentialledCluster()
+
+	sum := func(v string) string {
+		c := fake.NewClientBuilder().
+			WithScheme(testScheme(t)).
+			WithObjects(testSecret("whatsapp", map[string]string{"session": v})).
+			Build()
+
+		s, err := credentialsChecksum(context.Background(), c, app, deploymentv1alpha1.ClusterBot)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s
+	}
+
+	if sum("a") == sum("b") {
+		t.Error("expected checksum to change along with secret contents")
+	}
+
+	if sum("a") != sum("a") {
+		t.Error("expected checksum to be stable")
+	}
+}
+
+func TestDeployment_Credentials(t *testing.T) {
+	app := credentialledCluster()
+
+	t.Run("bot mounts session as a file", func(t *testing.T) {
+		d := mustDeployment(t, app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app))
+		container := d.Spec.Template.Spec.Containers[0]
+
+		if len(container.Env) != 1 || container.Env[0].Name != "WHATSAPP_SESSION_FILE" || container.Env[0].Value != "/credentials/whatsapp-session" {
+			t.Errorf("unexpected env %#v", container.Env)
+		}
+
+		if len(container.VolumeMounts) != 2 || container.VolumeMounts[1].MountPath != "/credentials/" {
+			t.Errorf("unexpected volume mounts %#v", container.VolumeMounts)
+		}
+
+		if len(d.Spec.Template.Spec.Volumes) != 2 || d.Spec.Template.Spec.Volumes[1].Projected == nil {
+			t.Errorf("unexpected volumes %#v", d.Spec.Template.Spec.Volumes)
+		}
+	})
+
+	t.Run("slacker receives tokens as env", func(t *testing.T) {
+		d := mustDeployment(t, app, deploymentv1alpha1.ClusterSlacker, GecSlackerLabels(app), GecSlackerSelectors(app))
+		container := d.Spec.Template.Spec.Containers[0]
+
+		if len(container.Env) != 2 {
+			t.Fatalf("unexpected env %#v", container.Env)
+		}
+
+		for i, expect := range []string{"SLACK_TOKEN", "SLACK_SIGNING_SECRET"} {
+			if container.Env[i].Name != expect || container.Env[i].ValueFrom.SecretKeyRef.Name != "slack" {
+				t.Errorf("unexpected env %#v", container.Env[i])
+			}
+		}
+
+		if len(d.Spec.Template.Spec.Volumes) != 0 {
+			t.Errorf("unexpected volumes %#v", d.Spec.Template.Spec.Volumes)
+		}
+	})
+}
+
+func TestClusterReconciler_SecretToClusters(t *testing.T) {
+	app := credentialledCluster()
+	app.Spec.Registry = &deploymentv1alpha1.RegistryCredentials{
+		Server:    "ghcr.io",
+		SecretRef: &deploymentv1alpha1.RegistrySecretRef{Name: "ghcr"},
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(testScheme(t)).
+		WithObjects(app).
+		Build()
+
+	r := &ClusterReconciler{Client: c, Scheme: c.Scheme()}
+
+	for _, test := range []struct {
+		name   string
+		expect int
+	}{
+		{"slack", 1},
+		{"whatsapp", 1},
+		{"ghcr", 1},
+		{"unrelated", 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := r.secretToClusters(testSecret(test.name, nil))
+			if test.expect != len(received) {
+				t.Errorf("expected %d requests, received %d", test.expect, len(received))
+			}
+		})
+	}
+}
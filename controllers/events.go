@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Event reasons. These are recorded against the Cluster, and are stable
+// so that alerting can match on them
+const (
+	ReasonCreated      = "Created"
+	ReasonUpdated      = "Updated"
+	ReasonDeleted      = "Deleted"
+	ReasonCreateFailed = "CreateFailed"
+	ReasonUpdateFailed = "UpdateFailed"
+	ReasonDeleteFailed = "DeleteFailed"
+
+	ReasonVersionChanged = "VersionChanged"
+	ReasonRolledBack     = "RolledBack"
+
+	ReasonSignatureNotChecked = "SignatureNotChecked"
+
+	ReasonRedisPreflightFailed = "RedisPreflightFailed"
+	ReasonRedisUnresolved      = "RedisUnresolved"
+
+	ReasonVolumeCreated      = "VolumeCreated"
+	ReasonVolumeResized      = "VolumeResized"
+	ReasonVolumeCreateFailed = "VolumeCreateFailed"
+	ReasonVolumeResizeFailed = "VolumeResizeFailed"
+)
+
+// events records Events against a Cluster
+type events struct {
+	recorder record.EventRecorder
+	cluster  *appv1alpha1.Cluster
+}
+
+// eventsFrom returns the events for a reconcile. Upserters called outside
+// of one, or while planning, record nothing
+func eventsFrom(ctx context.Context) *events {
+	e, ok := ctx.Value("events").(*events)
+	if !ok {
+		return new(events)
+	}
+
+	return e
+}
+
+func (e *events) normal(reason, format string, args ...interface{}) {
+	if e.recorder != nil {
+		e.recorder.Eventf(e.cluster, corev1.EventTypeNormal, reason, format, args...)
+	}
+}
+
+func (e *events) warning(reason, format string, args ...interface{}) {
+	if e.recorder != nil {
+		e.recorder.Eventf(e.cluster, corev1.EventTypeWarning, reason, format, args...)
+	}
+}
+
+// rollout records a new version of an app being rolled out, and that its
+// signature went unchecked. from is empty for a first rollout
+func (e *events) rollout(ca appv1alpha1.ClusterApp, from, to string) {
+	if from == to {
+		return
+	}
+
+	switch {
+	case from == "":
+		// Creating the Deployment has already been recorded
+
+	case olderVersion(to, from):
+		e.warning(ReasonRolledBack, "%s rolled back from %s to %s", ca, from, to)
+
+	default:
+		e.normal(ReasonVersionChanged, "%s changed from %s to %s", ca, from, to)
+	}
+
+	// Signatures aren't verified yet. Saying so, rather than saying
+	// nothing, stops a missing SignatureInvalid being read as a pass
+	if signed(ca) {
+		e.normal(ReasonSignatureNotChecked, "%s %s's signature wasn't checked", ca, to)
+	}
+}
+
+// olderVersion returns whether a is older than b. Versions which don't
+// parse aren't older than anything
+func olderVersion(a, b string) bool {
+	av, err := version.ParseSemantic(a)
+	if err != nil {
+		return false
+	}
+
+	bv, err := version.ParseSemantic(b)
+	if err != nil {
+		return false
+	}
+
+	return av.LessThan(bv)
+}
+
+// signed returns whether an app's images are signed. Only the built-in
+// apps are
+func signed(ca appv1alpha1.ClusterApp) bool {
+	switch ca {
+	case appv1alpha1.ClusterBot, appv1alpha1.ClusterProcessor, appv1alpha1.ClusterSlacker:
+		return true
+	}
+
+	return false
+}
+
+// eventWriter records an Event for every object upserters write
+type eventWriter struct {
+	client.Client
+
+	events *events
+}
+
+func (w eventWriter) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	err := w.Client.Create(ctx, obj, opts...)
+	w.record(obj, err, ReasonCreated, ReasonCreateFailed, ReasonVolumeCreated, ReasonVolumeCreateFailed)
+
+	return err
+}
+
+func (w eventWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	err := w.Client.Update(ctx, obj, opts...)
+
+	// The operator only ever updates volumes to resize them
+	w.record(obj, err, ReasonUpdated, ReasonUpdateFailed, ReasonVolumeResized, ReasonVolumeResizeFailed)
+
+	return err
+}
+
+func (w eventWriter) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := w.Client.Delete(ctx, obj, opts...)
+	w.record(obj, err, ReasonDeleted, ReasonDeleteFailed, ReasonDeleted, ReasonDeleteFailed)
+
+	return err
+}
+
+func (w eventWriter) record(obj client.Object, err error, ok, failed, volumeOK, volumeFailed string) {
+	if _, volume := obj.(*corev1.PersistentVolumeClaim); volume {
+		ok, failed = volumeOK, volumeFailed
+	}
+
+	kind := "object"
+	if gvk, gvkErr := apiutil.GVKForObject(obj, w.Scheme()); gvkErr == nil {
+		kind = gvk.Kind
+	}
+
+	if err != nil {
+		w.events.warning(failed, "%s %s: %v", kind, obj.GetName(), err)
+
+		return
+	}
+
+	w.events.normal(ok, "%s %s", kind, obj.GetName())
+}
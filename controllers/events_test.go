@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestOlderVersion(t *testing.T) {
+	for _, test := range []struct {
+		a, b   string
+		expect bool
+	}{
+		{"v0.0.1", "v0.0.2", true},
+		{"v0.0.2", "v0.0.1", false},
+		{"v0.1.0", "v0.1.0", false},
+		{"v0.1.0-rc.1", "v0.1.0", true},
+		{"latest", "v0.1.0", false},
+		{"v0.1.0", "latest", false},
+	} {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			if received := olderVersion(test.a, test.b); received != test.expect {
+				t.Errorf("expected %v, received %v", test.expect, received)
+			}
+		})
+	}
+}
+
+func drainEvents(recorder *record.FakeRecorder) (events []string) {
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return
+		}
+	}
+}
+
+func TestReconcile_Events(t *testing.T) {
+	app := bot.DeepCopy()
+
+	recorder := record.NewFakeRecorder(100)
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	r := &ClusterReconciler{
+		Client:   c,
+		Scheme:   testScheme(t),
+		Recorder: recorder,
+		RedisPreflight: func(context.Context, string) error {
+			return errors.New("redis redis.example.com:6379 unreachable")
+		},
+	}
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+
+	converge := func() {
+		t.Helper()
+
+		for i := 0; i < 20; i++ {
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: nn})
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+		}
+	}
+
+	setBotVersion := func(v string) {
+		t.Helper()
+
+		err := c.Get(context.Background(), nn, app)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		app.Spec.Bot.Version = v
+
+		err = c.Update(context.Background(), app)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	}
+
+	for _, test := range []struct {
+		name   string
+		change func()
+		expect []string
+	}{
+		{"create", func() {}, []string{
+			"Warning RedisPreflightFailed redis redis.example.com:6379 unreachable",
+			"Normal Created ServiceAccount my-test-cluster-gec-bot",
+			"Normal Created Deployment my-test-cluster-gec-bot",
+			"Normal VolumeCreated PersistentVolumeClaim my-test-cluster-gec-bot",
+			"Normal SignatureNotChecked gec-bot v0.0.1's signature wasn't checked",
+		}},
+		{"upgrade", func() { setBotVersion("v0.1.0") }, []string{
+			"Normal Updated Deployment my-test-cluster-gec-bot",
+			"Normal VersionChanged gec-bot changed from v0.0.1 to v0.1.0",
+			"Normal SignatureNotChecked gec-bot v0.1.0's signature wasn't checked",
+		}},
+		{"rollback", func() { setBotVersion("v0.0.9") }, []string{
+			"Warning RolledBack gec-bot rolled back from v0.1.0 to v0.0.9",
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.change()
+			converge()
+
+			received := strings.Join(drainEvents(recorder), "\n")
+			for _, e := range test.expect {
+				if !strings.Contains(received, e) {
+					t.Errorf("expected event %q, received\n%s", e, received)
+				}
+			}
+		})
+	}
+}
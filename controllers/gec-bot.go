@@ -7,8 +7,12 @@ import (
 var gecBotUpserters = []upserter{
 	ServiceAccount,
 	ConfigMap,
+	Credentials,
 	PVC,
 	Deployment,
+	PodDisruptionBudget,
+	Service,
+	NetworkPolicy,
 }
 
 func GecBotSelectors(app *appv1alpha1.Cluster) map[string]string {
@@ -24,3 +28,10 @@ func GecBotLabels(app *appv1alpha1.Cluster) map[string]string {
 
 	return l
 }
+
+func GecBotConfig(app *appv1alpha1.Cluster) map[string]string {
+	return map[string]string{
+		"REDIS_ADDR": app.RedisAddr(),
+		"DATABASE":   "/database/bot.db",
+	}
+}
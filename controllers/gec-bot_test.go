@@ -9,6 +9,8 @@ import (
 	"github.com/google/go-cmp/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 )
@@ -51,6 +53,15 @@ var (
 			Config: deploymentv1alpha1.Config{
 				RedisURL: "redis.example.com:6379",
 			},
+			Identity: &deploymentv1alpha1.CloudIdentity{
+				Provider: deploymentv1alpha1.IdentityProviderGKE,
+				GKE: &deploymentv1alpha1.GKEIdentity{
+					Project: "testing",
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{
+					{Name: "ecr-pull"},
+				},
+			},
 		},
 	}
 )
@@ -103,7 +114,7 @@ func TestBot_Deployment(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	received := deployment(bot, deploymentv1alpha1.ClusterBot, GecBotLabels(bot), GecBotSelectors(bot))
+	received := mustDeployment(t, bot, deploymentv1alpha1.ClusterBot, GecBotLabels(bot), GecBotSelectors(bot))
 	received.Spec.Template.Spec.SecurityContext = nil
 
 	if !cmp.Equal(expect.Spec, received.Spec) {
@@ -119,7 +130,7 @@ func TestBot_PVC(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	received := pvc(bot, deploymentv1alpha1.ClusterBot, GecBotLabels(bot))
+	received := pvc(deploymentv1alpha1.BuiltinDefaults(), bot, deploymentv1alpha1.ClusterBot, GecBotLabels(bot))
 	if !reflect.DeepEqual(expect, received) {
 		got, err := yaml.Marshal(received)
 		if err != nil {
@@ -129,3 +140,31 @@ func TestBot_PVC(t *testing.T) {
 		t.Errorf("expected\n%s", got)
 	}
 }
+
+func TestBot_NetworkPolicy(t *testing.T) {
+	expect := new(networkingv1.NetworkPolicy)
+
+	err := unmarshalFile("testdata/bot-netpol.yaml", expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := networkPolicy(bot, deploymentv1alpha1.ClusterBot, GecBotLabels(bot), GecBotSelectors(bot), nil)
+	if !cmp.Equal(expect, received) {
+		t.Fatal(cmp.Diff(expect, received))
+	}
+}
+
+func TestBot_PodDisruptionBudget(t *testing.T) {
+	expect := new(policyv1.PodDisruptionBudget)
+
+	err := unmarshalFile("testdata/bot-pdb.yaml", expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := podDisruptionBudget(bot, deploymentv1alpha1.ClusterBot, GecBotLabels(bot), GecBotSelectors(bot))
+	if !cmp.Equal(expect, received) {
+		t.Fatal(cmp.Diff(expect, received))
+	}
+}
@@ -8,6 +8,9 @@ var gecProcessorUpserters = []upserter{
 	ServiceAccount,
 	ConfigMap,
 	Deployment,
+	PodDisruptionBudget,
+	Service,
+	NetworkPolicy,
 }
 
 func GecProcessorSelectors(app *appv1alpha1.Cluster) map[string]string {
@@ -23,3 +26,9 @@ func GecProcessorLabels(app *appv1alpha1.Cluster) map[string]string {
 
 	return l
 }
+
+func GecProcessorConfig(app *appv1alpha1.Cluster) map[string]string {
+	return map[string]string{
+		"REDIS_HOSTNAME": redisHostname(app.RedisAddr()),
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 )
@@ -37,6 +38,15 @@ var (
 			Config: deploymentv1alpha1.Config{
 				RedisURL: "redis.example.com:6379",
 			},
+			Identity: &deploymentv1alpha1.CloudIdentity{
+				Provider: deploymentv1alpha1.IdentityProviderGKE,
+				GKE: &deploymentv1alpha1.GKEIdentity{
+					Project: "testing",
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{
+					{Name: "ecr-pull"},
+				},
+			},
 		},
 	}
 )
@@ -89,10 +99,24 @@ func TestProcessor_Deployment(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	received := deployment(processor, deploymentv1alpha1.ClusterProcessor, GecProcessorLabels(processor), GecProcessorSelectors(processor))
+	received := mustDeployment(t, processor, deploymentv1alpha1.ClusterProcessor, GecProcessorLabels(processor), GecProcessorSelectors(processor))
 	received.Spec.Template.Spec.SecurityContext = nil
 
 	if !cmp.Equal(expect.Spec, received.Spec) {
 		t.Fatal(cmp.Diff(expect, received))
 	}
 }
+
+func TestProcessor_NetworkPolicy(t *testing.T) {
+	expect := new(networkingv1.NetworkPolicy)
+
+	err := unmarshalFile("testdata/processor-netpol.yaml", expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := networkPolicy(processor, deploymentv1alpha1.ClusterProcessor, GecProcessorLabels(processor), GecProcessorSelectors(processor), nil)
+	if !cmp.Equal(expect, received) {
+		t.Fatal(cmp.Diff(expect, received))
+	}
+}
@@ -7,7 +7,11 @@ import (
 var gecSlackerUpserters = []upserter{
 	ServiceAccount,
 	ConfigMap,
+	Credentials,
 	Deployment,
+	PodDisruptionBudget,
+	Service,
+	NetworkPolicy,
 }
 
 func GecSlackerSelectors(app *appv1alpha1.Cluster) map[string]string {
@@ -23,3 +27,11 @@ func GecSlackerLabels(app *appv1alpha1.Cluster) map[string]string {
 
 	return l
 }
+
+func GecSlackerConfig(app *appv1alpha1.Cluster) map[string]string {
+	return map[string]string{
+		"REDIS_ADDR":      app.RedisAddr(),
+		"INCOMING_STREAM": "gec-processed",
+		"OUTGOING_STREAM": "gec-responses",
+	}
+}
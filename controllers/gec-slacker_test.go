@@ -8,6 +8,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 )
@@ -32,11 +33,23 @@ var (
 			Slacker: deploymentv1alpha1.Slacker{
 				App: deploymentv1alpha1.App{
 					Version: "v0.0.3",
+					Ports: []corev1.ContainerPort{
+						{Name: "metrics", ContainerPort: 8080},
+					},
 				},
 			},
 			Config: deploymentv1alpha1.Config{
 				RedisURL: "redis.example.com:6379",
 			},
+			Identity: &deploymentv1alpha1.CloudIdentity{
+				Provider: deploymentv1alpha1.IdentityProviderGKE,
+				GKE: &deploymentv1alpha1.GKEIdentity{
+					Project: "testing",
+				},
+				ImagePullSecrets: []corev1.LocalObjectReference{
+					{Name: "ecr-pull"},
+				},
+			},
 		},
 	}
 )
@@ -89,10 +102,43 @@ func TestSlacker_Deployment(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	received := deployment(slacker, deploymentv1alpha1.ClusterSlacker, GecSlackerLabels(slacker), GecSlackerSelectors(slacker))
+	received := mustDeployment(t, slacker, deploymentv1alpha1.ClusterSlacker, GecSlackerLabels(slacker), GecSlackerSelectors(slacker))
 	received.Spec.Template.Spec.SecurityContext = nil
 
 	if !cmp.Equal(expect.Spec, received.Spec) {
 		t.Fatal(cmp.Diff(expect, received))
 	}
 }
+
+func TestSlacker_Service(t *testing.T) {
+	expect := new(corev1.Service)
+
+	err := unmarshalFile("testdata/slacker-svc.yaml", expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := service(slacker, deploymentv1alpha1.ClusterSlacker, GecSlackerLabels(slacker), GecSlackerSelectors(slacker))
+	if !reflect.DeepEqual(expect, received) {
+		got, err := yaml.Marshal(received)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Errorf("expected\n%s", got)
+	}
+}
+
+func TestSlacker_NetworkPolicy(t *testing.T) {
+	expect := new(networkingv1.NetworkPolicy)
+
+	err := unmarshalFile("testdata/slacker-netpol.yaml", expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := networkPolicy(slacker, deploymentv1alpha1.ClusterSlacker, GecSlackerLabels(slacker), GecSlackerSelectors(slacker), nil)
+	if !cmp.Equal(expect, received) {
+		t.Fatal(cmp.Diff(expect, received))
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+)
+
+// maintenance decides whether upserters may apply disruptive changes to a
+// Cluster during a reconcile, and records the changes it holds back
+type maintenance struct {
+	open bool
+	next time.Time
+
+	pending    []appv1alpha1.PendingChange
+	reconciled map[string]bool
+}
+
+func newMaintenance(app *appv1alpha1.Cluster, t time.Time) (*maintenance, error) {
+	open, next, err := app.InMaintenanceWindow(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &maintenance{
+		open:       open,
+		next:       next,
+		reconciled: make(map[string]bool),
+	}, nil
+}
+
+// maintenanceFrom returns the maintenance gate for a reconcile. Upserters
+// called outside of one may always apply changes
+func maintenanceFrom(ctx context.Context) *maintenance {
+	m, ok := ctx.Value("maintenance").(*maintenance)
+	if !ok {
+		return &maintenance{open: true, reconciled: make(map[string]bool)}
+	}
+
+	return m
+}
+
+// reconciling notes that ca is being reconciled, so that changes pending
+// from earlier reconciles are replaced by those found in this one
+func (m *maintenance) reconciling(ca appv1alpha1.ClusterApp) {
+	m.reconciled[ca.String()] = true
+}
+
+// allow returns whether a disruptive change to an object may be applied
+// now, recording it as pending where it may not
+func (m *maintenance) allow(ca appv1alpha1.ClusterApp, kind, name string) bool {
+	if m.open {
+		return true
+	}
+
+	m.pending = append(m.pending, appv1alpha1.PendingChange{
+		App:  ca.String(),
+		Kind: kind,
+		Name: name,
+	})
+
+	return false
+}
+
+// pendingChanges merges the changes held back during this reconcile with
+// those previously held back for apps it didn't reconcile
+func (m *maintenance) pendingChanges(previous []appv1alpha1.PendingChange) (out []appv1alpha1.PendingChange) {
+	for _, pc := range previous {
+		if !m.reconciled[pc.App] {
+			out = append(out, pc)
+		}
+	}
+
+	out = append(out, m.pending...)
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].App != out[j].App {
+			return out[i].App < out[j].App
+		}
+
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+
+		return out[i].Name < out[j].Name
+	})
+
+	return
+}
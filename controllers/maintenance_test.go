@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMaintenance_PendingChanges(t *testing.T) {
+	previous := []appv1alpha1.PendingChange{
+		{App: "gec-bot", Kind: "Deployment", Name: "my-test-cluster-gec-bot"},
+		{App: "gec-slacker", Kind: "Deployment", Name: "my-test-cluster-gec-slacker"},
+	}
+
+	for _, test := range []struct {
+		name   string
+		setup  func(*maintenance)
+		expect []appv1alpha1.PendingChange
+	}{
+		{"nothing reconciled", func(m *maintenance) {}, previous},
+		{"applied", func(m *maintenance) {
+			m.open = true
+			m.reconciling(appv1alpha1.ClusterBot)
+			m.reconciling(appv1alpha1.ClusterSlacker)
+		}, nil},
+		{"partial reconcile", func(m *maintenance) {
+			m.reconciling(appv1alpha1.ClusterBot)
+			m.allow(appv1alpha1.ClusterBot, "PersistentVolumeClaim", "my-test-cluster-gec-bot")
+		}, []appv1alpha1.PendingChange{
+			{App: "gec-bot", Kind: "PersistentVolumeClaim", Name: "my-test-cluster-gec-bot"},
+			{App: "gec-slacker", Kind: "Deployment", Name: "my-test-cluster-gec-slacker"},
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			m := &maintenance{reconciled: make(map[string]bool)}
+			test.setup(m)
+
+			received := m.pendingChanges(previous)
+			if !reflect.DeepEqual(test.expect, received) {
+				t.Errorf("expected %#v, received %#v", test.expect, received)
+			}
+		})
+	}
+}
+
+func TestReconcile_MaintenanceWindow(t *testing.T) {
+	orig := now
+	t.Cleanup(func() { now = orig })
+
+	now = func() time.Time { return testNow }
+
+	app := bot.DeepCopy()
+	app.Spec.MaintenanceWindows = []appv1alpha1.MaintenanceWindow{
+		{Start: "22:00", Duration: metav1.Duration{Duration: time.Hour}},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	r := &ClusterReconciler{Client: c, Scheme: testScheme(t), FullResyncPeriod: 24 * time.Hour}
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+	req := ctrl.Request{NamespacedName: nn}
+
+	converge := func() (res ctrl.Result) {
+		t.Helper()
+
+		for i := 0; i < 5; i++ {
+			var err error
+
+			res, err = r.Reconcile(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+		}
+
+		return
+	}
+
+	botImage := func() string {
+		t.Helper()
+
+		d := new(appsv1.Deployment)
+
+		err := c.Get(context.Background(), types.NamespacedName{Name: app.InClusterName(appv1alpha1.ClusterBot), Namespace: app.Namespace}, d)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		return d.Spec.Template.Spec.Containers[0].Image
+	}
+
+	// Creating apps isn't disruptive, and so doesn't wait
+	converge()
+
+	original := botImage()
+
+	err := c.Get(context.Background(), nn, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	app.Spec.Bot.Version = "v0.0.9"
+
+	err = c.Update(context.Background(), app)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	res := converge()
+
+	t.Run("changes are held back", func(t *testing.T) {
+		if received := botImage(); received != original {
+			t.Errorf("expected %q, received %q", original, received)
+		}
+
+		err := c.Get(context.Background(), nn, app)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		expect := []appv1alpha1.PendingChange{{App: "gec-bot", Kind: "Deployment", Name: app.InClusterName(appv1alpha1.ClusterBot)}}
+		if !reflect.DeepEqual(expect, app.Status.PendingChanges) {
+			t.Errorf("expected %#v, received %#v", expect, app.Status.PendingChanges)
+		}
+
+		next := testNow.Add(10 * time.Hour)
+		if app.Status.NextMaintenanceWindow == nil || !app.Status.NextMaintenanceWindow.Equal(&metav1.Time{Time: next}) {
+			t.Errorf("expected next window at %s, received %v", next, app.Status.NextMaintenanceWindow)
+		}
+
+		if res.RequeueAfter != 10*time.Hour {
+			t.Errorf("expected requeue when the window opens, received %s", res.RequeueAfter)
+		}
+	})
+
+	t.Run("annotation applies changes", func(t *testing.T) {
+		app.Annotations = map[string]string{appv1alpha1.ApplyNowAnnotation: "true"}
+
+		err := c.Update(context.Background(), app)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		converge()
+
+		if received := botImage(); received == original {
+			t.Errorf("expected bot to be updated")
+		}
+
+		err = c.Get(context.Background(), nn, app)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if _, ok := app.Annotations[appv1alpha1.ApplyNowAnnotation]; ok {
+			t.Errorf("expected annotation to be removed")
+		}
+
+		if len(app.Status.PendingChanges) > 0 || app.Status.NextMaintenanceWindow != nil {
+			t.Errorf("expected nothing pending, received %#v", app.Status)
+		}
+	})
+}
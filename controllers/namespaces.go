@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"sort"
+	"strings"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// ParseNamespaces splits a comma separated list of namespaces, dropping
+// blanks and duplicates. An empty list means every namespace
+func ParseNamespaces(s string) (namespaces []string) {
+	seen := make(map[string]bool)
+
+	for _, ns := range strings.Split(s, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" || seen[ns] {
+			continue
+		}
+
+		seen[ns] = true
+		namespaces = append(namespaces, ns)
+	}
+
+	sort.Strings(namespaces)
+
+	return
+}
+
+// WatchNamespaces restricts a manager's cache, and so the Clusters it
+// reconciles, to the given namespaces. This allows the operator to run
+// with a Role in each namespace rather than a ClusterRole.
+//
+// No namespaces leaves opts watching the whole cluster
+func WatchNamespaces(opts ctrl.Options, namespaces []string) ctrl.Options {
+	switch len(namespaces) {
+	case 0:
+		opts.Namespace = ""
+		opts.NewCache = nil
+
+	case 1:
+		opts.Namespace = namespaces[0]
+		opts.NewCache = nil
+
+	default:
+		opts.Namespace = ""
+		opts.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+
+	return opts
+}
+
+// NewSharedCache builds a cache of the given namespaces, from which
+// Services and Secrets shared between Clusters, such as redis', are read.
+// The cache is started along with mgr.
+//
+// Only a manager watching particular namespaces needs one; a manager
+// watching the whole cluster already sees every namespace
+func NewSharedCache(mgr ctrl.Manager, namespaces []string) (cache.Cache, error) {
+	c, err := cache.MultiNamespacedCacheBuilder(namespaces)(mgr.GetConfig(), cache.Options{
+		Scheme: mgr.GetScheme(),
+		Mapper: mgr.GetRESTMapper(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c, mgr.Add(c)
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+func TestParseNamespaces(t *testing.T) {
+	for _, test := range []struct {
+		in     string
+		expect []string
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"tenant-a", []string{"tenant-a"}},
+		{"tenant-b, tenant-a,tenant-b,", []string{"tenant-a", "tenant-b"}},
+	} {
+		t.Run(test.in, func(t *testing.T) {
+			received := ParseNamespaces(test.in)
+			if !reflect.DeepEqual(test.expect, received) {
+				t.Errorf("expected %#v, received %#v", test.expect, received)
+			}
+		})
+	}
+}
+
+func TestWatchNamespaces(t *testing.T) {
+	for _, test := range []struct {
+		name            string
+		namespaces      []string
+		expectNamespace string
+		expectNewCache  bool
+	}{
+		{"all namespaces", nil, "", false},
+		{"single namespace", []string{"tenant-a"}, "tenant-a", false},
+		{"multiple namespaces", []string{"tenant-a", "tenant-b"}, "", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := WatchNamespaces(ctrl.Options{Namespace: "stale"}, test.namespaces)
+
+			if test.expectNamespace != received.Namespace {
+				t.Errorf("expected namespace %q, received %q", test.expectNamespace, received.Namespace)
+			}
+
+			if test.expectNewCache != (received.NewCache != nil) {
+				t.Errorf("expected NewCache set %v", test.expectNewCache)
+			}
+		})
+	}
+}
+
+// TestWatchNamespaces_Envtest runs the reconciler against a real API
+// server in each watch mode, and checks that only Clusters in watched
+// namespaces are reconciled. It is skipped unless envtest assets are
+// available, as they are via `make test`
+func TestWatchNamespaces_Envtest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS not set")
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+	}
+
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		env.Stop()
+	})
+
+	s := testScheme(t)
+
+	c, err := client.New(cfg, client.Options{Scheme: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := []string{"tenant-a", "tenant-b", "tenant-c"}
+	for _, ns := range all {
+		err = c.Create(context.Background(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, test := range []struct {
+		name       string
+		namespaces []string
+		expect     map[string]bool
+	}{
+		{"all namespaces", nil, map[string]bool{"tenant-a": true, "tenant-b": true, "tenant-c": true}},
+		{"single namespace", []string{"tenant-a"}, map[string]bool{"tenant-a": true}},
+		{"multiple namespaces", []string{"tenant-a", "tenant-b"}, map[string]bool{"tenant-a": true, "tenant-b": true}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mgr, err := ctrl.NewManager(cfg, WatchNamespaces(ctrl.Options{
+				Scheme:                 s,
+				MetricsBindAddress:     "0",
+				HealthProbeBindAddress: "0",
+			}, test.namespaces))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = (&ClusterReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()}).SetupWithManager(mgr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			go func() {
+				err := mgr.Start(ctx)
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+
+			// Each mode gets its own Cluster name, so that objects
+			// left behind by an earlier mode can't be mistaken for
+			// this one's
+			app := bot.DeepCopy()
+			app.Name = fmt.Sprintf("cluster-%d", i)
+
+			for _, ns := range all {
+				cluster := app.DeepCopy()
+				cluster.Namespace = ns
+
+				err = c.Create(context.Background(), cluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			name := app.InClusterName(deploymentv1alpha1.ClusterBot)
+
+			// Unwatched namespaces are given the same time to be
+			// (wrongly) reconciled as watched ones are to be reconciled
+			deadline := time.Now().Add(10 * time.Second)
+			for _, ns := range all {
+				var found bool
+
+				for !found && time.Now().Before(deadline) {
+					err = c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: ns}, new(corev1.ServiceAccount))
+					found = err == nil
+
+					if !found {
+						time.Sleep(100 * time.Millisecond)
+					}
+				}
+
+				if found != test.expect[ns] {
+					t.Errorf("%s: expected reconciled %v, received %v", ns, test.expect[ns], found)
+				}
+			}
+		})
+	}
+}
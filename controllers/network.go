@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	defaultRedisPort = 6379
+	dnsPort          = 53
+	httpsPort        = 443
+
+	// whatsappChatPort is the port WhatsApp clients fall back to for
+	// their long-lived chat connection when not multiplexing over 443
+	whatsappChatPort = 5222
+)
+
+var anywhere = []networkingv1.NetworkPolicyPeer{
+	{IPBlock: &networkingv1.IPBlock{CIDR: "0.0.0.0/0"}},
+	{IPBlock: &networkingv1.IPBlock{CIDR: "::/0"}},
+}
+
+func Service(ctx context.Context, c client.Client, s *runtime.Scheme, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
+	svc := service(app, ca, labels, selectors)
+
+	found := &corev1.Service{}
+
+	err = c.Get(ctx, types.NamespacedName{Name: svc.Name, Namespace: app.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return
+	}
+
+	notFound := errors.IsNotFound(err)
+	err = nil
+
+	// Apps which expose no ports get no Service; remove any we
+	// created previously
+	if len(svc.Spec.Ports) == 0 {
+		if !notFound && metav1.IsControlledBy(found, app) {
+			err = c.Delete(ctx, found)
+		}
+
+		return
+	}
+
+	err = ctrl.SetControllerReference(app, svc, s)
+	if err != nil {
+		return
+	}
+
+	if notFound {
+		err = c.Create(ctx, svc)
+
+		return
+	}
+
+	if !reflect.DeepEqual(found.Spec.Ports, svc.Spec.Ports) || !reflect.DeepEqual(found.Spec.Selector, svc.Spec.Selector) {
+		ctrllog.FromContext(ctx).V(1).Info("updating Service", "name", svc.Name, "diff", cmp.Diff(found.Spec, svc.Spec))
+
+		// ClusterIPs are immutable once allocated
+		svc.ResourceVersion = found.ResourceVersion
+		svc.Spec.ClusterIP = found.Spec.ClusterIP
+		svc.Spec.ClusterIPs = found.Spec.ClusterIPs
+
+		err = c.Update(ctx, svc)
+		if err == nil {
+			requeue = time.Second
+		}
+	}
+
+	return
+}
+
+func service(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) *corev1.Service {
+	ports := make([]corev1.ServicePort, 0)
+	for _, p := range app.InClusterApp(ca).Ports {
+		sp := corev1.ServicePort{
+			Name:       p.Name,
+			Protocol:   p.Protocol,
+			Port:       p.ContainerPort,
+			TargetPort: intstr.FromInt(int(p.ContainerPort)),
+		}
+
+		if sp.Protocol == "" {
+			sp.Protocol = corev1.ProtocolTCP
+		}
+
+		ports = append(ports, sp)
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.InClusterName(ca),
+			Namespace: app.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: selectors,
+			Ports:    ports,
+		},
+	}
+}
+
+func NetworkPolicy(ctx context.Context, c client.Client, s *runtime.Scheme, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
+	np := networkPolicy(app, ca, labels, selectors, redisFrom(ctx).peers)
+
+	err = ctrl.SetControllerReference(app, np, s)
+	if err != nil {
+		return
+	}
+
+	found := &networkingv1.NetworkPolicy{}
+
+	err = c.Get(ctx, types.NamespacedName{Name: np.Name, Namespace: app.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		err = c.Create(ctx, np)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(found.Spec, np.Spec) {
+		ctrllog.FromContext(ctx).V(1).Info("updating NetworkPolicy", "name", np.Name, "diff", cmp.Diff(found.Spec, np.Spec))
+
+		np.ResourceVersion = found.ResourceVersion
+
+		err = c.Update(ctx, np)
+		if err == nil {
+			requeue = time.Second
+		}
+	}
+
+	return
+}
+
+// networkPolicy returns the NetworkPolicy for a ClusterApp.
+//
+// ClusterMeta is special cased; it returns a default-deny policy which
+// selects every pod in the cluster. Each app then gets its own policy
+// which opens up only the traffic that app needs on top of that.
+//
+// redis are the peers redis was resolved to, which egress to redis is
+// limited to
+func networkPolicy(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string, redis []networkingv1.NetworkPolicyPeer) *networkingv1.NetworkPolicy {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.InClusterName(ca),
+			Namespace: app.Namespace,
+			Labels:    labels,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: selectors,
+			},
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeIngress,
+				networkingv1.PolicyTypeEgress,
+			},
+		},
+	}
+
+	if ca == deploymentv1alpha1.ClusterMeta {
+		np.Spec.PodSelector.MatchLabels = map[string]string{
+			"cluster": app.Name,
+		}
+
+		return np
+	}
+
+	np.Spec.Ingress = ingressRules(app, ca)
+	np.Spec.Egress = egressRules(app, ca, redis)
+
+	return np
+}
+
+// ingressRules allow pods within the same namespace, and whichever peers
+// the app's ingressFrom lists, to reach whichever ports an app exposes
+func ingressRules(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp) []networkingv1.NetworkPolicyIngressRule {
+	a := app.InClusterApp(ca)
+
+	ports := a.Ports
+	if len(ports) == 0 {
+		return nil
+	}
+
+	npp := make([]networkingv1.NetworkPolicyPort, len(ports))
+	for i, p := range ports {
+		npp[i] = policyPort(p.Protocol, int(p.ContainerPort))
+	}
+
+	return []networkingv1.NetworkPolicyIngressRule{{
+		From: append([]networkingv1.NetworkPolicyPeer{
+			{PodSelector: &metav1.LabelSelector{}},
+		}, a.IngressFrom...),
+		Ports: npp,
+	}}
+}
+
+// egressRules allow DNS and redis for every app, plus whichever
+// external services that app talks to. Redis is only reachable at redis,
+// or on its port anywhere when where it lives isn't known
+func egressRules(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, redis []networkingv1.NetworkPolicyPeer) []networkingv1.NetworkPolicyEgressRule {
+	rules := []networkingv1.NetworkPolicyEgressRule{
+		{
+			Ports: []networkingv1.NetworkPolicyPort{
+				policyPort(corev1.ProtocolUDP, dnsPort),
+				policyPort(corev1.ProtocolTCP, dnsPort),
+			},
+		},
+		{
+			To: redis,
+			Ports: []networkingv1.NetworkPolicyPort{
+				policyPort(corev1.ProtocolTCP, redisPort(app.RedisAddr())),
+			},
+		},
+	}
+
+	switch ca {
+	case deploymentv1alpha1.ClusterBot:
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{
+			To: anywhere,
+			Ports: []networkingv1.NetworkPolicyPort{
+				policyPort(corev1.ProtocolTCP, httpsPort),
+				policyPort(corev1.ProtocolTCP, whatsappChatPort),
+			},
+		})
+
+	case deploymentv1alpha1.ClusterSlacker:
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{
+			To: anywhere,
+			Ports: []networkingv1.NetworkPolicyPort{
+				policyPort(corev1.ProtocolTCP, httpsPort),
+			},
+		})
+	}
+
+	return rules
+}
+
+func policyPort(proto corev1.Protocol, port int) networkingv1.NetworkPolicyPort {
+	if proto == "" {
+		proto = corev1.ProtocolTCP
+	}
+
+	p := intstr.FromInt(port)
+
+	return networkingv1.NetworkPolicyPort{
+		Protocol: &proto,
+		Port:     &p,
+	}
+}
+
+func redisPort(s string) int {
+	if !isUri(s) {
+		s = fmt.Sprintf("redis://%s", s)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return defaultRedisPort
+	}
+
+	_, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return defaultRedisPort
+	}
+
+	i, err := strconv.Atoi(port)
+	if err != nil {
+		return defaultRedisPort
+	}
+
+	return i
+}
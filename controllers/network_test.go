@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRedisPort(t *testing.T) {
+	for _, test := range []struct {
+		in     string
+		expect int
+	}{
+		{"localhost:6379", 6379},
+		{"redis://localhost:6380", 6380},
+		{"localhost", 6379},
+		{"redis://localhost", 6379},
+		{"redis://localhost/0", 6379},
+		{"redis://localhost:16379/0", 16379},
+		{"redis://localhost:abc", 6379},
+	} {
+		t.Run(test.in, func(t *testing.T) {
+			received := redisPort(test.in)
+			if test.expect != received {
+				t.Errorf("expected %d, received %d", test.expect, received)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicy_DefaultDeny(t *testing.T) {
+	received := networkPolicy(bot, deploymentv1alpha1.ClusterMeta, GecMetaLabels(bot), nil, nil)
+
+	expectSelector := map[string]string{"cluster": "my-test-cluster"}
+	if !reflect.DeepEqual(expectSelector, received.Spec.PodSelector.MatchLabels) {
+		t.Errorf("expected %#v, received %#v", expectSelector, received.Spec.PodSelector.MatchLabels)
+	}
+
+	if len(received.Spec.PolicyTypes) != 2 {
+		t.Errorf("expected ingress and egress policy types, received %#v", received.Spec.PolicyTypes)
+	}
+
+	if len(received.Spec.Ingress) > 0 || len(received.Spec.Egress) > 0 {
+		t.Errorf("expected no rules, received %#v", received.Spec)
+	}
+}
+
+func TestService_NoPorts(t *testing.T) {
+	received := service(bot, deploymentv1alpha1.ClusterBot, GecBotLabels(bot), GecBotSelectors(bot))
+	if len(received.Spec.Ports) != 0 {
+		t.Errorf("expected no ports, received %#v", received.Spec.Ports)
+	}
+}
+
+func TestIngressRules_From(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Spec.Processor.Ports = []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9090}}
+	app.Spec.Processor.IngressFrom = []networkingv1.NetworkPolicyPeer{
+		{NamespaceSelector: namespaceSelector("monitoring")},
+	}
+
+	received := ingressRules(app, deploymentv1alpha1.ClusterProcessor)
+	if len(received) != 1 {
+		t.Fatalf("expected 1 rule, received %#v", received)
+	}
+
+	expect := []networkingv1.NetworkPolicyPeer{
+		{PodSelector: &metav1.LabelSelector{}},
+		{NamespaceSelector: namespaceSelector("monitoring")},
+	}
+
+	if !reflect.DeepEqual(expect, received[0].From) {
+		t.Errorf("expected %#v, received %#v", expect, received[0].From)
+	}
+}
+
+func TestEgressRules(t *testing.T) {
+	for _, test := range []struct {
+		ca          deploymentv1alpha1.ClusterApp
+		expectRules int
+	}{
+		{deploymentv1alpha1.ClusterBot, 3},
+		{deploymentv1alpha1.ClusterProcessor, 2},
+		{deploymentv1alpha1.ClusterSlacker, 3},
+	} {
+		t.Run(test.ca.String(), func(t *testing.T) {
+			var received []networkingv1.NetworkPolicyEgressRule = egressRules(bot, test.ca, nil)
+			if test.expectRules != len(received) {
+				t.Errorf("expected %d rules, received %d", test.expectRules, len(received))
+			}
+		})
+	}
+}
+
+func TestEgressRules_Redis(t *testing.T) {
+	redis := []networkingv1.NetworkPolicyPeer{{NamespaceSelector: namespaceSelector("data")}}
+
+	for _, test := range []struct {
+		name   string
+		redis  []networkingv1.NetworkPolicyPeer
+		expect []networkingv1.NetworkPolicyPeer
+	}{
+		{"resolved", redis, redis},
+		{"unknown", nil, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := egressRules(bot, deploymentv1alpha1.ClusterProcessor, test.redis)[1]
+
+			if !reflect.DeepEqual(test.expect, received.To) {
+				t.Errorf("expected %#v, received %#v", test.expect, received.To)
+			}
+
+			if len(received.Ports) != 1 || received.Ports[0].Port.IntValue() != 6379 {
+				t.Errorf("expected redis' port, received %#v", received.Ports)
+			}
+		})
+	}
+}
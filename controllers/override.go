@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// podTemplateOverride strategic-merges an app's podTemplateOverride over
+// its generated pod template, refusing any override which weakens the
+// operator's security settings
+func podTemplateOverride(app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, tmpl corev1.PodTemplateSpec) (out corev1.PodTemplateSpec, err error) {
+	override := app.InClusterApp(ca).PodTemplateOverride
+	if override == nil || len(override.Raw) == 0 {
+		return tmpl, nil
+	}
+
+	original, err := json.Marshal(tmpl)
+	if err != nil {
+		return
+	}
+
+	patched, err := strategicpatch.StrategicMergePatch(original, override.Raw, corev1.PodTemplateSpec{})
+	if err != nil {
+		return out, fmt.Errorf("%s: invalid podTemplateOverride: %w", ca, err)
+	}
+
+	err = json.Unmarshal(patched, &out)
+	if err != nil {
+		return out, fmt.Errorf("%s: invalid podTemplateOverride: %w", ca, err)
+	}
+
+	err = validateOverride(tmpl, out)
+	if err != nil {
+		return out, fmt.Errorf("%s: podTemplateOverride %w", ca, err)
+	}
+
+	return
+}
+
+// validateOverride compares an overridden pod template with the one the
+// operator generated, returning an error naming every security setting
+// the override changed
+func validateOverride(generated, overridden corev1.PodTemplateSpec) error {
+	var forbidden []string
+
+	if !reflect.DeepEqual(generated.Spec.AutomountServiceAccountToken, overridden.Spec.AutomountServiceAccountToken) {
+		forbidden = append(forbidden, "automountServiceAccountToken")
+	}
+
+	if generated.Spec.ServiceAccountName != overridden.Spec.ServiceAccountName {
+		forbidden = append(forbidden, "serviceAccountName")
+	}
+
+	if overridden.Spec.HostNetwork || overridden.Spec.HostPID || overridden.Spec.HostIPC {
+		forbidden = append(forbidden, "host namespaces")
+	}
+
+	// Pods have no privileged flag of their own; host namespaces, above,
+	// and Windows host processes are their equivalent
+	if psc := overridden.Spec.SecurityContext; psc != nil {
+		if psc.RunAsNonRoot != nil && !*psc.RunAsNonRoot {
+			forbidden = append(forbidden, "securityContext.runAsNonRoot")
+		}
+
+		if psc.RunAsUser != nil && *psc.RunAsUser == 0 {
+			forbidden = append(forbidden, "securityContext.runAsUser")
+		}
+
+		if psc.WindowsOptions != nil && psc.WindowsOptions.HostProcess != nil && *psc.WindowsOptions.HostProcess {
+			forbidden = append(forbidden, "securityContext.windowsOptions.hostProcess")
+		}
+
+		if psc.SeccompProfile != nil && psc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			forbidden = append(forbidden, "securityContext.seccompProfile")
+		}
+	}
+
+	for _, v := range overridden.Spec.Volumes {
+		if v.HostPath != nil {
+			forbidden = append(forbidden, fmt.Sprintf("volumes[%s].hostPath", v.Name))
+		}
+	}
+
+	for _, c := range overridden.Spec.InitContainers {
+		forbidden = append(forbidden, validateContainer("initContainers", c)...)
+	}
+
+	for _, c := range overridden.Spec.Containers {
+		forbidden = append(forbidden, validateContainer("containers", c)...)
+	}
+
+	if len(forbidden) > 0 {
+		return fmt.Errorf("may not override %s", strings.Join(forbidden, ", "))
+	}
+
+	return nil
+}
+
+func validateContainer(field string, c corev1.Container) (forbidden []string) {
+	path := fmt.Sprintf("%s[%s].securityContext", field, c.Name)
+
+	sc := c.SecurityContext
+	if sc == nil {
+		return []string{path}
+	}
+
+	if sc.RunAsNonRoot == nil || !*sc.RunAsNonRoot {
+		forbidden = append(forbidden, path+".runAsNonRoot")
+	}
+
+	if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+		forbidden = append(forbidden, path+".runAsUser")
+	}
+
+	if sc.ReadOnlyRootFilesystem == nil || !*sc.ReadOnlyRootFilesystem {
+		forbidden = append(forbidden, path+".readOnlyRootFilesystem")
+	}
+
+	if sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess {
+		forbidden = append(forbidden, path+".windowsOptions.hostProcess")
+	}
+
+	if sc.Privileged == nil || *sc.Privileged {
+		forbidden = append(forbidden, path+".privileged")
+	}
+
+	if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+		forbidden = append(forbidden, path+".allowPrivilegeEscalation")
+	}
+
+	if sc.Capabilities == nil || len(sc.Capabilities.Add) > 0 || !dropsAll(sc.Capabilities.Drop) {
+		forbidden = append(forbidden, path+".capabilities")
+	}
+
+	return
+}
+
+func dropsAll(caps []corev1.Capability) bool {
+	for _, c := range caps {
+		if c == "ALL" {
+			return true
+		}
+	}
+
+	return false
+}
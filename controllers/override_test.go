@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"testing"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPodTemplateOverride(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		override    string
+		expectError bool
+	}{
+		{"unset", "", false},
+		{"priority class and host aliases", `{"spec": {"priorityClassName": "gec-critical", "hostAliases": [{"ip": "10.0.0.1", "hostnames": ["redis.internal"]}]}}`, false},
+		{"runtime class", `{"spec": {"runtimeClassName": "gvisor"}}`, false},
+		{"container resources", `{"spec": {"containers": [{"name": "my-test-cluster-gec-bot", "resources": {"limits": {"memory": "256Mi"}}}]}}`, false},
+		{"annotations", `{"metadata": {"annotations": {"example.com/team": "gec"}}}`, false},
+		{"run as root", `{"spec": {"containers": [{"name": "my-test-cluster-gec-bot", "securityContext": {"runAsNonRoot": false}}]}}`, true},
+		{"capabilities", `{"spec": {"containers": [{"name": "my-test-cluster-gec-bot", "securityContext": {"capabilities": {"drop": ["NET_RAW"]}}}]}}`, true},
+		{"added capabilities", `{"spec": {"containers": [{"name": "my-test-cluster-gec-bot", "securityContext": {"capabilities": {"add": ["NET_ADMIN"]}}}]}}`, true},
+		{"service account token", `{"spec": {"automountServiceAccountToken": true}}`, true},
+		{"host network", `{"spec": {"hostNetwork": true}}`, true},
+		{"pod run as root", `{"spec": {"securityContext": {"runAsNonRoot": false}}}`, true},
+		{"pod run as uid 0", `{"spec": {"securityContext": {"runAsUser": 0}}}`, true},
+		{"pod run as uid 1000", `{"spec": {"securityContext": {"runAsUser": 1000}}}`, false},
+		{"pod host process", `{"spec": {"securityContext": {"windowsOptions": {"hostProcess": true}}}}`, true},
+		{"pod unconfined", `{"spec": {"securityContext": {"seccompProfile": {"type": "Unconfined"}}}}`, true},
+		{"host path", `{"spec": {"volumes": [{"name": "docker", "hostPath": {"path": "/var/run/docker.sock"}}]}}`, true},
+		{"run as uid 0", `{"spec": {"containers": [{"name": "my-test-cluster-gec-bot", "securityContext": {"runAsUser": 0}}]}}`, true},
+		{"writable root filesystem", `{"spec": {"containers": [{"name": "my-test-cluster-gec-bot", "securityContext": {"readOnlyRootFilesystem": false}}]}}`, true},
+		{"new container", `{"spec": {"containers": [{"name": "debug", "image": "busybox"}]}}`, true},
+		{"invalid", `{"spec": {"containers": "nope"}}`, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			app := bot.DeepCopy()
+			if test.override != "" {
+				app.Spec.Bot.PodTemplateOverride = &runtime.RawExtension{Raw: []byte(test.override)}
+			}
+
+			generated := mustDeployment(t, app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app)).Spec.Template
+
+			received, err := podTemplateOverride(app, deploymentv1alpha1.ClusterBot, generated)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if received.Spec.ServiceAccountName != generated.Spec.ServiceAccountName || len(received.Spec.Containers) != 1 {
+				t.Errorf("expected generated template to be kept, received %#v", received.Spec)
+			}
+		})
+	}
+}
+
+func TestPodTemplateOverride_Applied(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Spec.Bot.PodTemplateOverride = &runtime.RawExtension{Raw: []byte(`{
+		"metadata": {"annotations": {"example.com/team": "gec"}},
+		"spec": {
+			"priorityClassName": "gec-critical",
+			"containers": [{"name": "my-test-cluster-gec-bot", "resources": {"limits": {"memory": "256Mi"}}}]
+		}
+	}`)}
+
+	generated := mustDeployment(t, app, deploymentv1alpha1.ClusterBot, GecBotLabels(app), GecBotSelectors(app)).Spec.Template
+	generated.Annotations = map[string]string{configChecksumAnnotation: "abc"}
+
+	received, err := podTemplateOverride(app, deploymentv1alpha1.ClusterBot, generated)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if received.Spec.PriorityClassName != "gec-critical" {
+		t.Errorf("expected priority class, received %q", received.Spec.PriorityClassName)
+	}
+
+	if received.Annotations[configChecksumAnnotation] != "abc" || received.Annotations["example.com/team"] != "gec" {
+		t.Errorf("expected annotations to be merged, received %#v", received.Annotations)
+	}
+
+	c := received.Spec.Containers[0]
+	if memory := c.Resources.Limits.Memory().String(); memory != "256Mi" {
+		t.Errorf("expected memory limit 256Mi, received %s", memory)
+	}
+
+	if cpu := c.Resources.Limits.Cpu().String(); cpu != "100m" {
+		t.Errorf("expected cpu limit to be kept, received %s", cpu)
+	}
+
+	if c.Image != generated.Spec.Containers[0].Image {
+		t.Errorf("expected image to be kept, received %q", c.Image)
+	}
+}
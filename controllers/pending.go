@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// pendingApps records which ClusterApps of each Cluster have seen events
+// since that Cluster was last reconciled.
+//
+// Requests carry only a Cluster's name, so this is how Reconcile learns
+// that, say, only slacker's ConfigMap changed. Clusters are reconciled in
+// full where nothing is recorded, where their spec has changed since
+// their last full reconcile, or where that was longer ago than
+// fullResyncPeriod; a full reconcile is always safe
+type pendingApps struct {
+	mu   sync.Mutex
+	apps map[types.NamespacedName]map[appv1alpha1.ClusterApp]bool
+	full map[types.NamespacedName]fullReconcile
+}
+
+type fullReconcile struct {
+	generation int64
+	at         time.Time
+}
+
+// add records an event for ca; UnknownClusterApp stands for an event
+// which could concern any app, and so forces a full reconcile
+func (p *pendingApps) add(nn types.NamespacedName, ca appv1alpha1.ClusterApp) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.apps == nil {
+		p.apps = make(map[types.NamespacedName]map[appv1alpha1.ClusterApp]bool)
+	}
+
+	if p.apps[nn] == nil {
+		p.apps[nn] = make(map[appv1alpha1.ClusterApp]bool)
+	}
+
+	p.apps[nn][ca] = true
+}
+
+// take returns, and forgets, the apps recorded for a Cluster in
+// reconcile order, or nil where the Cluster should be reconciled in full
+func (p *pendingApps) take(app *appv1alpha1.Cluster, period time.Duration) (apps []appv1alpha1.ClusterApp) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+
+	pending := p.apps[nn]
+	delete(p.apps, nn)
+
+	last, ok := p.full[nn]
+	switch {
+	case !ok, last.generation != app.Generation, now().Sub(last.at) >= period:
+		return nil
+
+	case len(pending) == 0, pending[appv1alpha1.UnknownClusterApp]:
+		return nil
+	}
+
+	// Components which have been removed, or whose names are reserved,
+	// aren't reconciled, and so can't be pending
+	order, _ := reconcileOrder(app)
+
+	for _, ca := range order {
+		if pending[ca] {
+			apps = append(apps, ca)
+		}
+	}
+
+	return
+}
+
+// done records a successful full reconcile of a Cluster
+func (p *pendingApps) done(app *appv1alpha1.Cluster) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.full == nil {
+		p.full = make(map[types.NamespacedName]fullReconcile)
+	}
+
+	p.full[types.NamespacedName{Name: app.Name, Namespace: app.Namespace}] = fullReconcile{
+		generation: app.Generation,
+		at:         now(),
+	}
+}
+
+// forget drops everything recorded for a deleted Cluster
+func (p *pendingApps) forget(nn types.NamespacedName) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.apps, nn)
+	delete(p.full, nn)
+}
+
+// appFromLabels returns the ClusterApp an object belongs to, from the
+// app label set by GecBotSelectors and friends
+func appFromLabels(labels map[string]string) appv1alpha1.ClusterApp {
+	name := labels["app"]
+
+	if name == GecMetaSelectors(new(appv1alpha1.Cluster))["app"] {
+		return appv1alpha1.ClusterMeta
+	}
+
+	ca := appv1alpha1.ClusterApp(name)
+	if _, ok := componentFor(ca); !ok {
+		return appv1alpha1.UnknownClusterApp
+	}
+
+	return ca
+}
+
+// ownedAppHandler enqueues the Cluster which controls an object, first
+// recording which of that Cluster's apps the object belongs to
+type ownedAppHandler struct {
+	pending *pendingApps
+}
+
+func (h ownedAppHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(e.Object, q)
+}
+
+func (h ownedAppHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(e.ObjectOld, q)
+	h.enqueue(e.ObjectNew, q)
+}
+
+func (h ownedAppHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(e.Object, q)
+}
+
+func (h ownedAppHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.enqueue(e.Object, q)
+}
+
+func (h ownedAppHandler) enqueue(o client.Object, q workqueue.RateLimitingInterface) {
+	if o == nil {
+		return
+	}
+
+	ref := metav1.GetControllerOf(o)
+	if ref == nil || ref.Kind != "Cluster" || ref.APIVersion != appv1alpha1.GroupVersion.String() {
+		return
+	}
+
+	nn := types.NamespacedName{Name: ref.Name, Namespace: o.GetNamespace()}
+
+	h.pending.add(nn, appFromLabels(o.GetLabels()))
+	q.Add(reconcile.Request{NamespacedName: nn})
+}
+
+// fullHandler wraps a handler which enqueues whole Clusters, such as for
+// changes to Secrets they reference, marking each for a full reconcile
+type fullHandler struct {
+	pending *pendingApps
+	inner   handler.EventHandler
+}
+
+func (h fullHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.inner.Create(e, h.wrap(q))
+}
+
+func (h fullHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.inner.Update(e, h.wrap(q))
+}
+
+func (h fullHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.inner.Delete(e, h.wrap(q))
+}
+
+func (h fullHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.inner.Generic(e, h.wrap(q))
+}
+
+func (h fullHandler) wrap(q workqueue.RateLimitingInterface) workqueue.RateLimitingInterface {
+	return fullQueue{RateLimitingInterface: q, pending: h.pending}
+}
+
+// fullQueue marks every request added to it for a full reconcile
+type fullQueue struct {
+	workqueue.RateLimitingInterface
+	pending *pendingApps
+}
+
+func (q fullQueue) Add(item interface{}) {
+	if req, ok := item.(reconcile.Request); ok {
+		q.pending.add(req.NamespacedName, appv1alpha1.UnknownClusterApp)
+	}
+
+	q.RateLimitingInterface.Add(item)
+}
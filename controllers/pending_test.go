@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPendingApps_Take(t *testing.T) {
+	orig := now
+	t.Cleanup(func() { now = orig })
+
+	now = func() time.Time { return testNow }
+
+	app := bot.DeepCopy()
+	app.Generation = 2
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+
+	for _, test := range []struct {
+		name   string
+		setup  func(*pendingApps)
+		expect []deploymentv1alpha1.ClusterApp
+	}{
+		{"never reconciled", func(p *pendingApps) {
+			p.add(nn, deploymentv1alpha1.ClusterSlacker)
+		}, nil},
+		{"nothing pending", func(p *pendingApps) {
+			p.done(app)
+		}, nil},
+		{"single app", func(p *pendingApps) {
+			p.done(app)
+			p.add(nn, deploymentv1alpha1.ClusterSlacker)
+		}, []deploymentv1alpha1.ClusterApp{deploymentv1alpha1.ClusterSlacker}},
+		{"several apps, in reconcile order", func(p *pendingApps) {
+			p.done(app)
+			p.add(nn, deploymentv1alpha1.ClusterSlacker)
+			p.add(nn, deploymentv1alpha1.ClusterMeta)
+			p.add(nn, deploymentv1alpha1.ClusterSlacker)
+		}, []deploymentv1alpha1.ClusterApp{deploymentv1alpha1.ClusterMeta, deploymentv1alpha1.ClusterSlacker}},
+		{"unknown app", func(p *pendingApps) {
+			p.done(app)
+			p.add(nn, deploymentv1alpha1.ClusterSlacker)
+			p.add(nn, deploymentv1alpha1.UnknownClusterApp)
+		}, nil},
+		{"spec changed since last full reconcile", func(p *pendingApps) {
+			old := app.DeepCopy()
+			old.Generation = 1
+
+			p.done(old)
+			p.add(nn, deploymentv1alpha1.ClusterSlacker)
+		}, nil},
+		{"full resync due", func(p *pendingApps) {
+			now = func() time.Time { return testNow.Add(-time.Hour) }
+			p.done(app)
+			now = func() time.Time { return testNow }
+
+			p.add(nn, deploymentv1alpha1.ClusterSlacker)
+		}, nil},
+		{"forgotten", func(p *pendingApps) {
+			p.done(app)
+			p.add(nn, deploymentv1alpha1.ClusterSlacker)
+			p.forget(nn)
+		}, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p := new(pendingApps)
+			test.setup(p)
+
+			received := p.take(app, defaultFullResyncPeriod)
+			if !reflect.DeepEqual(test.expect, received) {
+				t.Errorf("expected %v, received %v", test.expect, received)
+			}
+
+			// Taking clears what was pending
+			if again := p.take(app, defaultFullResyncPeriod); again != nil {
+				t.Errorf("expected nothing pending, received %v", again)
+			}
+		})
+	}
+}
+
+func TestAppFromLabels(t *testing.T) {
+	for _, test := range []struct {
+		labels map[string]string
+		expect deploymentv1alpha1.ClusterApp
+	}{
+		{GecBotLabels(bot), deploymentv1alpha1.ClusterBot},
+		{GecProcessorLabels(bot), deploymentv1alpha1.ClusterProcessor},
+		{GecSlackerLabels(bot), deploymentv1alpha1.ClusterSlacker},
+		{GecMetaLabels(bot), deploymentv1alpha1.ClusterMeta},
+		{map[string]string{"app": "gec-translator"}, deploymentv1alpha1.ClusterApp("gec-translator")},
+		{map[string]string{"app": "registry"}, deploymentv1alpha1.UnknownClusterApp},
+		{nil, deploymentv1alpha1.UnknownClusterApp},
+	} {
+		t.Run(test.expect.String(), func(t *testing.T) {
+			received := appFromLabels(test.labels)
+			if test.expect != received {
+				t.Errorf("expected %s, received %s", test.expect, received)
+			}
+		})
+	}
+}
+
+func TestReconcile_OnlyAffectedApp(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Generation = 1
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	r := &ClusterReconciler{Client: c, Scheme: testScheme(t)}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: app.Name, Namespace: app.Namespace}}
+
+	// Converge on a fully reconciled Cluster
+	var err error
+	for i := 0; i < 5; i++ {
+		_, err = r.Reconcile(context.Background(), req)
+	}
+
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	botDeployment := &appsv1.Deployment{}
+	slackerConfig := &corev1.ConfigMap{}
+
+	get := func(name string, o client.Object) error {
+		return c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: app.Namespace}, o)
+	}
+
+	for name, o := range map[string]client.Object{
+		app.InClusterName(deploymentv1alpha1.ClusterBot):     botDeployment,
+		app.InClusterName(deploymentv1alpha1.ClusterSlacker): slackerConfig,
+	} {
+		err = get(name, o)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		err = c.Delete(context.Background(), o)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	}
+
+	// An event on slacker's ConfigMap reconciles slacker alone, and so
+	// leaves the missing bot Deployment be
+	r.pending.add(req.NamespacedName, deploymentv1alpha1.ClusterSlacker)
+
+	_, err = r.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if err = get(app.InClusterName(deploymentv1alpha1.ClusterSlacker), new(corev1.ConfigMap)); err != nil {
+		t.Errorf("expected slacker ConfigMap to be recreated: %v", err)
+	}
+
+	if err = get(app.InClusterName(deploymentv1alpha1.ClusterBot), new(appsv1.Deployment)); err == nil {
+		t.Errorf("expected bot Deployment to be left alone")
+	}
+
+	// With nothing pending, the next reconcile is a full one
+	_, err = r.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if err = get(app.InClusterName(deploymentv1alpha1.ClusterBot), new(appsv1.Deployment)); err != nil {
+		t.Errorf("expected bot Deployment to be recreated: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// planner is handed to upserters in place of the reconciler's client while
+// a Cluster is being planned. Reads pass through, while writes are recorded
+// rather than made
+type planner struct {
+	client.Client
+
+	cluster *appv1alpha1.Cluster
+	app     appv1alpha1.ClusterApp
+
+	changes []appv1alpha1.PlannedChange
+	index   map[string]int
+
+	// written holds the objects upserters would have created or updated
+	written []client.Object
+
+	// rendering leaves out objects which can't be built offline
+	rendering bool
+}
+
+func newPlanner(c client.Client, cluster *appv1alpha1.Cluster) *planner {
+	return &planner{
+		Client:  c,
+		cluster: cluster,
+		index:   make(map[string]int),
+	}
+}
+
+// Get records objects the Cluster controls as unchanged, until a write
+// says otherwise
+func (p *planner) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	err := p.Client.Get(ctx, key, obj)
+	if err == nil && metav1.IsControlledBy(obj, p.cluster) {
+		p.record(obj, appv1alpha1.PlanNone)
+	}
+
+	return err
+}
+
+func (p *planner) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	p.record(obj, appv1alpha1.PlanCreate)
+	p.written = append(p.written, obj.DeepCopyObject().(client.Object))
+
+	return nil
+}
+
+func (p *planner) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	p.record(obj, appv1alpha1.PlanUpdate)
+	p.written = append(p.written, obj.DeepCopyObject().(client.Object))
+
+	return nil
+}
+
+func (p *planner) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	p.record(obj, appv1alpha1.PlanUpdate)
+
+	return nil
+}
+
+func (p *planner) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	p.record(obj, appv1alpha1.PlanDelete)
+
+	return nil
+}
+
+func (p *planner) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return nil
+}
+
+// Status records status writes as updates, rather than making them
+func (p *planner) Status() client.StatusWriter {
+	return plannerStatusWriter{p}
+}
+
+type plannerStatusWriter struct {
+	p *planner
+}
+
+func (w plannerStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	w.p.record(obj, appv1alpha1.PlanUpdate)
+
+	return nil
+}
+
+func (w plannerStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	w.p.record(obj, appv1alpha1.PlanUpdate)
+
+	return nil
+}
+
+func (p *planner) record(obj client.Object, action appv1alpha1.PlanAction) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(obj, p.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+
+	key := kind + "/" + obj.GetName()
+	if i, ok := p.index[key]; ok {
+		if action != appv1alpha1.PlanNone {
+			p.changes[i].Action = action
+		}
+
+		return
+	}
+
+	p.index[key] = len(p.changes)
+	p.changes = append(p.changes, appv1alpha1.PlannedChange{
+		App:    p.app.String(),
+		Kind:   kind,
+		Name:   obj.GetName(),
+		Action: action,
+	})
+}
+
+// planning returns whether a Cluster is planned, rather than reconciled
+func (r *ClusterReconciler) planning(app *appv1alpha1.Cluster) bool {
+	if r.Plan {
+		return true
+	}
+
+	_, ok := app.Annotations[appv1alpha1.PlanAnnotation]
+
+	return ok
+}
+
+// plan runs every upserter for a Cluster against a planner, and writes
+// what they would have done into the Cluster's status
+func (r *ClusterReconciler) plan(ctx context.Context, app *appv1alpha1.Cluster) (ctrl.Result, error) {
+	p := newPlanner(r.Client, app)
+
+	err := r.planApps(ctx, app, p)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	plan := &appv1alpha1.Plan{
+		ObservedGeneration: app.Generation,
+		Changes:            p.changes,
+	}
+
+	if !reflect.DeepEqual(app.Status.Plan, plan) {
+		app.Status.Plan = plan
+
+		err = r.Status().Update(ctx, app)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: r.fullResyncPeriod()}, nil
+}
+
+// planApps runs every upserter for a Cluster against p
+func (r *ClusterReconciler) planApps(ctx context.Context, app *appv1alpha1.Cluster, p *planner) error {
+	apps, err := reconcileOrder(app)
+	if err != nil {
+		return err
+	}
+
+	// Plans show every change, including those a maintenance window
+	// would hold back
+	ctx = context.WithValue(ctx, "maintenance", &maintenance{open: true, reconciled: make(map[string]bool)})
+	ctx = context.WithValue(ctx, "plan", p)
+
+	redis, err := r.resolveRedis(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	ctx = context.WithValue(ctx, "redis", redis)
+	ctx = context.WithValue(ctx, "defaults", r.defaults())
+	ctx = context.WithValue(ctx, "registry", r.RegistryTokens)
+	desired := redis.apply(app)
+
+	for _, ca := range apps {
+		p.app = ca
+
+		_, err = r.reconcileApp(ctx, desired, ca)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writer returns the client upserters write through; the planner while
+// planning, and otherwise the reconciler's own, recording an Event for
+// each write
+func (r *ClusterReconciler) writer(ctx context.Context) client.Client {
+	if p, ok := ctx.Value("plan").(*planner); ok {
+		return p
+	}
+
+	return eventWriter{Client: r.Client, events: eventsFrom(ctx)}
+}
+
+// halt returns whether to stop after an upserter. Upserters ask to be
+// requeued after a write, to see it settle, but while planning nothing is
+// written, so every upserter runs
+func halt(ctx context.Context, requeue time.Duration, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return requeue > 0 && !planned(ctx)
+}
+
+// planned returns whether upserters are being run against a planner
+func planned(ctx context.Context) bool {
+	_, ok := ctx.Value("plan").(*planner)
+
+	return ok
+}
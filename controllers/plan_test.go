@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile_Plan(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Annotations = map[string]string{appv1alpha1.PlanAnnotation: "true"}
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	r := &ClusterReconciler{Client: c, Scheme: testScheme(t)}
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+	req := ctrl.Request{NamespacedName: nn}
+
+	reconcile := func(times int) {
+		t.Helper()
+
+		for i := 0; i < times; i++ {
+			_, err := r.Reconcile(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+		}
+	}
+
+	actions := func() map[string]appv1alpha1.PlanAction {
+		t.Helper()
+
+		err := c.Get(context.Background(), nn, app)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if app.Status.Plan == nil {
+			t.Fatalf("expected a plan")
+		}
+
+		out := make(map[string]appv1alpha1.PlanAction)
+		for _, pc := range app.Status.Plan.Changes {
+			out[pc.Kind+"/"+pc.Name] = pc.Action
+		}
+
+		return out
+	}
+
+	botDeployment := "Deployment/" + app.InClusterName(appv1alpha1.ClusterBot)
+
+	t.Run("nothing deployed", func(t *testing.T) {
+		reconcile(1)
+
+		deployments := new(appsv1.DeploymentList)
+
+		err := c.List(context.Background(), deployments)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if len(deployments.Items) > 0 {
+			t.Errorf("expected nothing to be deployed, received %d Deployments", len(deployments.Items))
+		}
+
+		received := actions()
+		for _, key := range []string{
+			botDeployment,
+			"PersistentVolumeClaim/" + app.InClusterName(appv1alpha1.ClusterBot),
+			"ConfigMap/" + app.InClusterName(appv1alpha1.ClusterMeta),
+			"NetworkPolicy/" + app.InClusterName(appv1alpha1.ClusterSlacker),
+		} {
+			if received[key] != appv1alpha1.PlanCreate {
+				t.Errorf("%s: expected %q, received %q", key, appv1alpha1.PlanCreate, received[key])
+			}
+		}
+	})
+
+	t.Run("version bump", func(t *testing.T) {
+		// Deploy for real, then plan a new bot version
+		delete(app.Annotations, appv1alpha1.PlanAnnotation)
+
+		err := c.Update(context.Background(), app)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		reconcile(5)
+
+		err = c.Get(context.Background(), nn, app)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if app.Status.Plan != nil {
+			t.Errorf("expected plan to be cleared, received %#v", app.Status.Plan)
+		}
+
+		app.Annotations = map[string]string{appv1alpha1.PlanAnnotation: "true"}
+		app.Spec.Bot.Version = "v0.0.9"
+
+		err = c.Update(context.Background(), app)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		d := new(appsv1.Deployment)
+
+		err = c.Get(context.Background(), types.NamespacedName{Name: app.InClusterName(appv1alpha1.ClusterBot), Namespace: app.Namespace}, d)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		image := d.Spec.Template.Spec.Containers[0].Image
+
+		reconcile(1)
+
+		// The bot rolls, and its SBOM changes in the meta ConfigMap
+		updated := map[string]bool{
+			botDeployment: true,
+			"ConfigMap/" + app.InClusterName(appv1alpha1.ClusterMeta): true,
+		}
+
+		for key, action := range actions() {
+			expect := appv1alpha1.PlanNone
+			if updated[key] {
+				expect = appv1alpha1.PlanUpdate
+			}
+
+			if expect != action {
+				t.Errorf("%s: expected %q, received %q", key, expect, action)
+			}
+		}
+
+		err = c.Get(context.Background(), types.NamespacedName{Name: app.InClusterName(appv1alpha1.ClusterBot), Namespace: app.Namespace}, d)
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+
+		if received := d.Spec.Template.Spec.Containers[0].Image; received != image {
+			t.Errorf("expected %q to be left alone, received %q", image, received)
+		}
+	})
+}
+
+func TestPlanner_Status(t *testing.T) {
+	app := bot.DeepCopy()
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	p := newPlanner(c, app)
+
+	update := app.DeepCopy()
+	update.Status.Phase = appv1alpha1.ClusterReady
+
+	err := p.Status().Update(context.Background(), update)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if len(p.changes) != 1 || p.changes[0].Action != appv1alpha1.PlanUpdate {
+		t.Errorf("expected the status update to be recorded, received %#v", p.changes)
+	}
+
+	received := new(appv1alpha1.Cluster)
+
+	err = c.Get(context.Background(), types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, received)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if received.Status.Phase == appv1alpha1.ClusterReady {
+		t.Errorf("expected status to be left alone")
+	}
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// DefaultRateLimitBaseDelay and DefaultRateLimitMaxDelay bound how
+	// long a failing Cluster waits before it's retried
+	DefaultRateLimitBaseDelay = time.Second
+	DefaultRateLimitMaxDelay  = 5 * time.Minute
+
+	// DefaultRateLimitQPS and DefaultRateLimitBurst bound retries across
+	// every Cluster, so that an upgrade which touches dozens of them at
+	// once doesn't flood the API server
+	DefaultRateLimitQPS   = 5
+	DefaultRateLimitBurst = 50
+)
+
+// NewRateLimiter returns a rate limiter which backs each Cluster off
+// exponentially between baseDelay and maxDelay, while limiting retries
+// overall to qps, with bursts of up to burst.
+//
+// Zero values take the defaults above
+func NewRateLimiter(baseDelay, maxDelay time.Duration, qps float64, burst int) workqueue.RateLimiter {
+	if baseDelay <= 0 {
+		baseDelay = DefaultRateLimitBaseDelay
+	}
+
+	if maxDelay <= 0 {
+		maxDelay = DefaultRateLimitMaxDelay
+	}
+
+	if qps <= 0 {
+		qps = DefaultRateLimitQPS
+	}
+
+	if burst <= 0 {
+		burst = DefaultRateLimitBurst
+	}
+
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(qps), burst)},
+	)
+}
+
+// deploymentChanged passes Deployment updates which either change the
+// Deployment's spec, or change how many of its pods are up. Status
+// churn which changes neither, such as condition heartbeats, is dropped
+type deploymentChanged struct {
+	predicate.Funcs
+}
+
+func (deploymentChanged) Update(e event.UpdateEvent) bool {
+	o, ok := e.ObjectOld.(*appsv1.Deployment)
+	if !ok {
+		return true
+	}
+
+	n, ok := e.ObjectNew.(*appsv1.Deployment)
+	if !ok {
+		return true
+	}
+
+	return o.Generation != n.Generation ||
+		o.Status.ObservedGeneration != n.Status.ObservedGeneration ||
+		o.Status.Replicas != n.Status.Replicas ||
+		o.Status.ReadyReplicas != n.Status.ReadyReplicas ||
+		o.Status.AvailableReplicas != n.Status.AvailableReplicas ||
+		o.Status.UpdatedReplicas != n.Status.UpdatedReplicas ||
+		!n.DeletionTimestamp.Equal(o.DeletionTimestamp)
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestDeploymentChanged_Update(t *testing.T) {
+	base := &appsv1.Deployment{}
+	base.Generation = 2
+	base.Status = appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 1, ReadyReplicas: 1, AvailableReplicas: 1, UpdatedReplicas: 1}
+
+	for _, test := range []struct {
+		name   string
+		mutate func(*appsv1.Deployment)
+		expect bool
+	}{
+		{"nothing changed", func(*appsv1.Deployment) {}, false},
+		{"condition heartbeat", func(d *appsv1.Deployment) {
+			d.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentAvailable}}
+		}, false},
+		{"resource version only", func(d *appsv1.Deployment) { d.ResourceVersion = "2" }, false},
+		{"spec changed", func(d *appsv1.Deployment) { d.Generation = 3 }, true},
+		{"rollout observed", func(d *appsv1.Deployment) { d.Status.ObservedGeneration = 3 }, true},
+		{"pod became unready", func(d *appsv1.Deployment) { d.Status.ReadyReplicas = 0 }, true},
+		{"pod became unavailable", func(d *appsv1.Deployment) { d.Status.AvailableReplicas = 0 }, true},
+		{"pod updated", func(d *appsv1.Deployment) { d.Status.UpdatedReplicas = 2 }, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			n := base.DeepCopy()
+			test.mutate(n)
+
+			received := deploymentChanged{}.Update(event.UpdateEvent{ObjectOld: base, ObjectNew: n})
+			if test.expect != received {
+				t.Errorf("expected %v, received %v", test.expect, received)
+			}
+		})
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	rl := NewRateLimiter(0, 0, 0, 0)
+
+	// The first failure is retried after the base delay, and failures
+	// back off from there, up to the max delay
+	if d := rl.When("a"); d != DefaultRateLimitBaseDelay {
+		t.Errorf("expected %s, received %s", DefaultRateLimitBaseDelay, d)
+	}
+
+	if d := rl.When("a"); d != 2*DefaultRateLimitBaseDelay {
+		t.Errorf("expected %s, received %s", 2*DefaultRateLimitBaseDelay, d)
+	}
+
+	for i := 0; i < 20; i++ {
+		rl.When("a")
+	}
+
+	if d := rl.When("a"); d != DefaultRateLimitMaxDelay {
+		t.Errorf("expected %s, received %s", DefaultRateLimitMaxDelay, d)
+	}
+
+	// Other Clusters are unaffected
+	if d := rl.When("b"); d != DefaultRateLimitBaseDelay {
+		t.Errorf("expected %s, received %s", DefaultRateLimitBaseDelay, d)
+	}
+
+	rl.Forget("a")
+	if d := rl.When("a"); d != DefaultRateLimitBaseDelay {
+		t.Errorf("expected %s after forgetting, received %s", DefaultRateLimitBaseDelay, d)
+	}
+}
+
+func TestNewRateLimiter_Custom(t *testing.T) {
+	rl := NewRateLimiter(10*time.Millisecond, 20*time.Millisecond, 100, 10)
+
+	rl.When("a")
+	rl.When("a")
+
+	if d := rl.When("a"); d != 20*time.Millisecond {
+		t.Errorf("expected 20ms, received %s", d)
+	}
+}
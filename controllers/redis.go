@@ -0,0 +1,424 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// redisPasswordKey is the key the copy of redis' password is held under
+const redisPasswordKey = "password"
+
+// resolvedRedis is what a Cluster's redis references resolved to
+type resolvedRedis struct {
+	// addr is the address of redis' Service, where it references one
+	addr     string
+	password []byte
+
+	// peers are where redis lives, which apps' egress to it is limited
+	// to. Nil where that isn't known
+	peers []networkingv1.NetworkPolicyPeer
+}
+
+// apply returns app as its apps' configs should be built from, with the
+// address its redis Service resolved to
+func (r *resolvedRedis) apply(app *appv1alpha1.Cluster) *appv1alpha1.Cluster {
+	if app.Spec.Config.RedisService == nil {
+		return app
+	}
+
+	app = app.DeepCopy()
+	app.Status.RedisAddress = r.addr
+
+	return app
+}
+
+// redisFrom returns the redis resolved for a reconcile
+func redisFrom(ctx context.Context) *resolvedRedis {
+	r, ok := ctx.Value("redis").(*resolvedRedis)
+	if !ok {
+		return new(resolvedRedis)
+	}
+
+	return r
+}
+
+func redisSecretName(app *appv1alpha1.Cluster) string {
+	return app.Name + "-redis"
+}
+
+// resolveRedis follows a Cluster's references to redis' Service and
+// password, which may be in other namespaces, and works out where redis
+// lives
+func (r *ClusterReconciler) resolveRedis(ctx context.Context, app *appv1alpha1.Cluster) (*resolvedRedis, error) {
+	err := checkRedisNamespaces(app, r.SharedNamespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	c := r.redisReader()
+	resolved := new(resolvedRedis)
+
+	if app.Spec.Config.RedisURL != "" {
+		var err error
+
+		resolved.peers, err = r.redisURLPeers(ctx, app)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if nn, ok := app.RedisService(); ok {
+		svc := new(corev1.Service)
+
+		err := c.Get(ctx, nn, svc)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				err = fmt.Errorf("redis service %s not found", nn)
+			}
+
+			return nil, err
+		}
+
+		port, err := redisServicePort(svc, app.Spec.Config.RedisService.Port)
+		if err != nil {
+			return nil, err
+		}
+
+		resolved.addr = fmt.Sprintf("%s.%s.svc:%d", svc.Name, svc.Namespace, port)
+		resolved.peers = servicePeers(svc)
+	}
+
+	if nn, ok := app.RedisPasswordSecret(); ok {
+		secret := new(corev1.Secret)
+
+		err := c.Get(ctx, nn, secret)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				err = fmt.Errorf("redis password secret %s not found", nn)
+			}
+
+			return nil, err
+		}
+
+		key := app.Spec.Config.RedisPasswordSecret.PasswordKey()
+
+		resolved.password = secret.Data[key]
+		if len(resolved.password) == 0 {
+			return nil, fmt.Errorf("redis password secret %s has no key %q", nn, key)
+		}
+	}
+
+	return resolved, nil
+}
+
+// checkRedisNamespaces refuses references to redis' Service or password
+// Secret outside of a Cluster's own namespace, unless they're in one of
+// the shared namespaces. Otherwise, anyone able to create a Cluster could
+// have the operator copy any Secret into their namespace
+func checkRedisNamespaces(app *appv1alpha1.Cluster, shared []string) error {
+	if nn, ok := app.RedisService(); ok && !redisNamespaceAllowed(app, nn.Namespace, shared) {
+		return fmt.Errorf("redis service %s is in namespace %q, which isn't shared with --shared-namespace", nn, nn.Namespace)
+	}
+
+	if nn, ok := app.RedisPasswordSecret(); ok && !redisNamespaceAllowed(app, nn.Namespace, shared) {
+		return fmt.Errorf("redis password secret %s is in namespace %q, which isn't shared with --shared-namespace", nn, nn.Namespace)
+	}
+
+	return nil
+}
+
+func redisNamespaceAllowed(app *appv1alpha1.Cluster, ns string, shared []string) bool {
+	if ns == app.Namespace {
+		return true
+	}
+
+	for _, s := range shared {
+		if s == ns {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redisServicePort returns the port of a Service named name, or its first
+// port where name is empty
+func redisServicePort(svc *corev1.Service, name string) (int32, error) {
+	for _, p := range svc.Spec.Ports {
+		if name == "" || p.Name == name {
+			return p.Port, nil
+		}
+	}
+
+	if name == "" {
+		return 0, fmt.Errorf("redis service %s/%s has no ports", svc.Namespace, svc.Name)
+	}
+
+	return 0, fmt.Errorf("redis service %s/%s has no port %q", svc.Namespace, svc.Name, name)
+}
+
+// servicePeers returns the pods a Service routes to. Services without a
+// selector may route anywhere in their namespace
+func servicePeers(svc *corev1.Service) []networkingv1.NetworkPolicyPeer {
+	peer := networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: namespaceSelector(svc.Namespace),
+	}
+
+	if len(svc.Spec.Selector) > 0 {
+		peer.PodSelector = &metav1.LabelSelector{MatchLabels: svc.Spec.Selector}
+	}
+
+	return []networkingv1.NetworkPolicyPeer{peer}
+}
+
+// redisURLPeers returns where the host of a Cluster's RedisURL lives; the
+// address it is, the namespace of the Service it names, or what it resolves
+// to outside of the cluster.
+//
+// Hosts outside of the cluster are only resolved where the reconciler can
+// look them up, and are otherwise left open
+func (r *ClusterReconciler) redisURLPeers(ctx context.Context, app *appv1alpha1.Cluster) ([]networkingv1.NetworkPolicyPeer, error) {
+	host := redisHostname(app.Spec.Config.RedisURL)
+
+	if ip := net.ParseIP(host); ip != nil {
+		return []networkingv1.NetworkPolicyPeer{ipPeer(ip)}, nil
+	}
+
+	if ns, ok := serviceNamespace(host, app.Namespace); ok {
+		return []networkingv1.NetworkPolicyPeer{{NamespaceSelector: namespaceSelector(ns)}}, nil
+	}
+
+	// name.namespace is only taken to be a Service where one exists, as
+	// it's just as likely to be an external host such as redislabs.com
+	if nn, ok := qualifiedServiceName(host); ok {
+		if r.redisReader().Get(ctx, nn, new(corev1.Service)) == nil {
+			return []networkingv1.NetworkPolicyPeer{{NamespaceSelector: namespaceSelector(nn.Namespace)}}, nil
+		}
+	}
+
+	if r.LookupHost == nil {
+		return nil, nil
+	}
+
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("resolving redis host %s: %w", host, err)
+	}
+
+	// Lookups needn't return addresses in the same order each time, which
+	// would otherwise update the NetworkPolicy on every reconcile
+	sort.Strings(addrs)
+
+	peers := make([]networkingv1.NetworkPolicyPeer, 0, len(addrs))
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil {
+			peers = append(peers, ipPeer(ip))
+		}
+	}
+
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("redis host %s resolved to no addresses", host)
+	}
+
+	return peers, nil
+}
+
+// serviceNamespace returns the namespace of the Service host names, where
+// it can only be a Service's in-cluster name; name, or name.namespace.svc
+// and beyond
+func serviceNamespace(host, def string) (string, bool) {
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+
+	switch {
+	case len(labels) == 1:
+		return def, true
+
+	case len(labels) > 2 && labels[2] == "svc":
+		return labels[1], true
+	}
+
+	return "", false
+}
+
+// qualifiedServiceName returns the Service host would name, were it of the
+// form name.namespace
+func qualifiedServiceName(host string) (types.NamespacedName, bool) {
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(labels) != 2 {
+		return types.NamespacedName{}, false
+	}
+
+	return types.NamespacedName{Name: labels[0], Namespace: labels[1]}, true
+}
+
+func namespaceSelector(ns string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{corev1.LabelMetadataName: ns},
+	}
+}
+
+func ipPeer(ip net.IP) networkingv1.NetworkPolicyPeer {
+	bits := 128
+	if ip.To4() != nil {
+		bits = 32
+	}
+
+	return networkingv1.NetworkPolicyPeer{
+		IPBlock: &networkingv1.IPBlock{CIDR: fmt.Sprintf("%s/%d", ip, bits)},
+	}
+}
+
+// RedisSecret copies redis' password into the Cluster's namespace, where
+// apps can reference it
+func RedisSecret(ctx context.Context, c client.Client, s *runtime.Scheme, app *appv1alpha1.Cluster, ca appv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
+	password := redisFrom(ctx).password
+	if password == nil {
+		return
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      redisSecretName(app),
+			Namespace: app.Namespace,
+			Labels:    labels,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			redisPasswordKey: password,
+		},
+	}
+
+	err = ctrl.SetControllerReference(app, secret, s)
+	if err != nil {
+		return
+	}
+
+	found := new(corev1.Secret)
+
+	err = c.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		err = c.Create(ctx, secret)
+
+		return
+	}
+
+	if err != nil || reflect.DeepEqual(found.Data, secret.Data) {
+		return
+	}
+
+	secret.ResourceVersion = found.ResourceVersion
+	err = c.Update(ctx, secret)
+
+	return
+}
+
+// redisEnv passes redis' password to an app, where there is one
+func redisEnv(app *appv1alpha1.Cluster) []corev1.EnvVar {
+	if app.Spec.Config.RedisPasswordSecret == nil {
+		return nil
+	}
+
+	return []corev1.EnvVar{{
+		Name: "REDIS_PASSWORD",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: redisSecretName(app),
+				},
+				Key: redisPasswordKey,
+			},
+		},
+	}}
+}
+
+// redisToClusters maps a Service or Secret to the Clusters whose redis
+// references it, in any namespace
+func (r *ClusterReconciler) redisToClusters(o client.Object) (requests []reconcile.Request) {
+	clusters := new(appv1alpha1.ClusterList)
+
+	err := r.List(context.Background(), clusters)
+	if err != nil {
+		return
+	}
+
+	nn := types.NamespacedName{Name: o.GetName(), Namespace: o.GetNamespace()}
+
+	for _, cluster := range clusters.Items {
+		var ref types.NamespacedName
+
+		switch o.(type) {
+		case *corev1.Service:
+			ref, _ = cluster.RedisService()
+		case *corev1.Secret:
+			ref, _ = cluster.RedisPasswordSecret()
+		}
+
+		if ref == nn {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace},
+			})
+		}
+	}
+
+	return
+}
+
+// sharedReader reads objects in shared namespaces from a cache of their
+// own, and everything else through Reader
+type sharedReader struct {
+	client.Reader
+
+	shared     client.Reader
+	namespaces []string
+}
+
+// Get implements client.Reader
+func (s sharedReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	for _, ns := range s.namespaces {
+		if ns == key.Namespace {
+			return s.shared.Get(ctx, key, obj)
+		}
+	}
+
+	return s.Reader.Get(ctx, key, obj)
+}
+
+// redisReader returns the reader redis' Service and password Secret are
+// read through
+func (r *ClusterReconciler) redisReader() client.Reader {
+	if r.SharedCache == nil {
+		return r.Client
+	}
+
+	return sharedReader{Reader: r.Client, shared: r.SharedCache, namespaces: r.SharedNamespaces}
+}
+
+// DialRedis checks a Cluster's redis accepts connections, for use as a
+// ClusterReconciler's RedisPreflight
+func DialRedis(ctx context.Context, addr string) error {
+	d := net.Dialer{Timeout: 5 * time.Second}
+
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(redisHostname(addr), strconv.Itoa(redisPort(addr))))
+	if err != nil {
+		return fmt.Errorf("redis %s unreachable: %w", addr, err)
+	}
+
+	return conn.Close()
+}
@@ -0,0 +1,345 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// sharedRedisCluster is bot, with redis referenced from the data namespace
+func sharedRedisCluster() *deploymentv1alpha1.Cluster {
+	app := bot.DeepCopy()
+	app.Spec.Config.RedisURL = ""
+	app.Spec.Config.RedisService = &deploymentv1alpha1.RedisServiceRef{Name: "redis", Namespace: "data"}
+	app.Spec.Config.RedisPasswordSecret = &deploymentv1alpha1.RedisSecretRef{Name: "redis", Namespace: "data"}
+
+	return app
+}
+
+func redisService(ns string, ports ...corev1.ServicePort) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "redis", Namespace: ns},
+		Spec:       corev1.ServiceSpec{Ports: ports},
+	}
+}
+
+func redisPassword(ns, key string) *corev1.Secret {
+	s := testSecret("redis", map[string]string{key: "hunter2"})
+	s.Namespace = ns
+
+	return s
+}
+
+func TestResolveRedis(t *testing.T) {
+	named := sharedRedisCluster()
+	named.Spec.Config.RedisService.Port = "tls"
+
+	sameNamespace := sharedRedisCluster()
+	sameNamespace.Spec.Config.RedisService.Namespace = ""
+	sameNamespace.Spec.Config.RedisPasswordSecret = nil
+
+	customKey := sharedRedisCluster()
+	customKey.Spec.Config.RedisPasswordSecret.Key = "redis-password"
+
+	unsharedService := sharedRedisCluster()
+	unsharedService.Spec.Config.RedisService.Namespace = "infra"
+
+	unsharedSecret := sharedRedisCluster()
+	unsharedSecret.Spec.Config.RedisService.Namespace = ""
+	unsharedSecret.Spec.Config.RedisPasswordSecret.Namespace = "kube-system"
+
+	redisPorts := []corev1.ServicePort{{Name: "redis", Port: 6379}, {Name: "tls", Port: 6380}}
+
+	for _, test := range []struct {
+		name           string
+		app            *deploymentv1alpha1.Cluster
+		objects        []client.Object
+		expectAddr     string
+		expectPassword string
+		expectErr      bool
+	}{
+		{"redis url", bot, nil, "", "", false},
+		{"other namespace", sharedRedisCluster(), []client.Object{redisService("data", redisPorts...), redisPassword("data", "password")}, "redis.data.svc:6379", "hunter2", false},
+		{"named port", named, []client.Object{redisService("data", redisPorts...), redisPassword("data", "password")}, "redis.data.svc:6380", "hunter2", false},
+		{"same namespace", sameNamespace, []client.Object{redisService("testing", redisPorts...)}, "redis.testing.svc:6379", "", false},
+		{"custom key", customKey, []client.Object{redisService("data", redisPorts...), redisPassword("data", "redis-password")}, "redis.data.svc:6379", "hunter2", false},
+		{"missing service", sharedRedisCluster(), []client.Object{redisPassword("data", "password")}, "", "", true},
+		{"missing port", named, []client.Object{redisService("data", redisPorts[0]), redisPassword("data", "password")}, "", "", true},
+		{"missing secret", sharedRedisCluster(), []client.Object{redisService("data", redisPorts...)}, "", "", true},
+		{"missing key", customKey, []client.Object{redisService("data", redisPorts...), redisPassword("data", "password")}, "", "", true},
+		{"unshared service", unsharedService, []client.Object{redisService("infra", redisPorts...), redisPassword("data", "password")}, "", "", true},
+		{"unshared secret", unsharedSecret, []client.Object{redisService("testing", redisPorts...), redisPassword("kube-system", "password")}, "", "", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := &ClusterReconciler{
+				Client:           fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(test.objects...).Build(),
+				SharedNamespaces: []string{"data"},
+			}
+
+			received, err := r.resolveRedis(context.Background(), test.app)
+			if err == nil && test.expectErr {
+				t.Fatalf("expected error")
+			} else if err != nil && !test.expectErr {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if test.expectErr {
+				return
+			}
+
+			if received.addr != test.expectAddr {
+				t.Errorf("expected address %q, received %q", test.expectAddr, received.addr)
+			}
+
+			if string(received.password) != test.expectPassword {
+				t.Errorf("expected password %q, received %q", test.expectPassword, received.password)
+			}
+
+			expectRedisAddr := test.expectAddr
+			if expectRedisAddr == "" {
+				expectRedisAddr = test.app.Spec.Config.RedisURL
+			}
+
+			if addr := received.apply(test.app).RedisAddr(); addr != expectRedisAddr {
+				t.Errorf("expected apps to use %q, received %q", expectRedisAddr, addr)
+			}
+		})
+	}
+}
+
+func TestRedisToClusters(t *testing.T) {
+	other := sharedRedisCluster()
+	other.Name = "other-cluster"
+	other.Spec.Config.RedisService.Name = "other-redis"
+
+	plain := bot.DeepCopy()
+	plain.Name = "plain-cluster"
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(plain, sharedRedisCluster(), other).Build()
+	r := &ClusterReconciler{Client: c}
+
+	for _, test := range []struct {
+		name   string
+		obj    client.Object
+		expect []string
+	}{
+		{"service", redisService("data"), []string{"my-test-cluster"}},
+		{"secret", redisPassword("data", "password"), []string{"my-test-cluster", "other-cluster"}},
+		{"other namespace", redisService("testing"), nil},
+		{"unreferenced", &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "other-redis", Namespace: "testing"}}, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := r.redisToClusters(test.obj)
+			if len(received) != len(test.expect) {
+				t.Fatalf("expected %d requests, received %#v", len(test.expect), received)
+			}
+
+			for i, name := range test.expect {
+				if received[i].Name != name || received[i].Namespace != "testing" {
+					t.Errorf("expected %s, received %s", name, received[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSharedReader(t *testing.T) {
+	shared := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(redisService("data")).Build()
+	own := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(redisService("testing")).Build()
+
+	reader := sharedReader{Reader: own, shared: shared, namespaces: []string{"data"}}
+
+	for _, ns := range []string{"data", "testing"} {
+		err := reader.Get(context.Background(), types.NamespacedName{Name: "redis", Namespace: ns}, new(corev1.Service))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %#v", ns, err)
+		}
+	}
+}
+
+func TestReconcile_SharedRedis(t *testing.T) {
+	app := sharedRedisCluster()
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(
+		app,
+		redisService("data", corev1.ServicePort{Name: "redis", Port: 6379}),
+		redisPassword("data", "password"),
+	).Build()
+	r := &ClusterReconciler{Client: c, Scheme: testScheme(t), SharedNamespaces: []string{"data"}}
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+
+	for i := 0; i < 20; i++ {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: nn})
+		if err != nil {
+			t.Fatalf("unexpected error: %#v", err)
+		}
+	}
+
+	err := c.Get(context.Background(), nn, app)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if app.Status.RedisAddress != "redis.data.svc:6379" {
+		t.Errorf("expected status to record redis' address, received %q", app.Status.RedisAddress)
+	}
+
+	cm := new(corev1.ConfigMap)
+
+	err = c.Get(context.Background(), types.NamespacedName{Name: "my-test-cluster-gec-bot", Namespace: "testing"}, cm)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if cm.Data["REDIS_ADDR"] != "redis.data.svc:6379" {
+		t.Errorf("expected REDIS_ADDR redis.data.svc:6379, received %q", cm.Data["REDIS_ADDR"])
+	}
+
+	secret := new(corev1.Secret)
+
+	err = c.Get(context.Background(), types.NamespacedName{Name: "my-test-cluster-redis", Namespace: "testing"}, secret)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if string(secret.Data["password"]) != "hunter2" {
+		t.Errorf("expected the password to be copied, received %q", secret.Data["password"])
+	}
+
+	d := new(appsv1.Deployment)
+
+	err = c.Get(context.Background(), types.NamespacedName{Name: "my-test-cluster-gec-bot", Namespace: "testing"}, d)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	var found bool
+	for _, e := range d.Spec.Template.Spec.Containers[0].Env {
+		if e.Name == "REDIS_PASSWORD" && e.ValueFrom != nil && e.ValueFrom.SecretKeyRef.Name == "my-test-cluster-redis" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("expected REDIS_PASSWORD from my-test-cluster-redis, received %#v", d.Spec.Template.Spec.Containers[0].Env)
+	}
+}
+
+func TestReconcile_RedisUnresolved(t *testing.T) {
+	app := sharedRedisCluster()
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	r := &ClusterReconciler{Client: c, Scheme: testScheme(t)}
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: app.Name, Namespace: app.Namespace}})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	d := new(appsv1.Deployment)
+
+	err = c.Get(context.Background(), types.NamespacedName{Name: "my-test-cluster-gec-bot", Namespace: "testing"}, d)
+	if err == nil {
+		t.Errorf("expected nothing to be deployed without redis")
+	}
+}
+
+func TestRedisPeers(t *testing.T) {
+	lookup := func(_ context.Context, host string) ([]string, error) {
+		switch host {
+		case "redis.example.com":
+			return []string{"10.0.0.12", "10.0.0.11"}, nil
+		case "redis.example":
+			return []string{"10.0.0.21"}, nil
+		case "empty.example.com":
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+
+	ipBlocks := func(cidrs ...string) (peers []networkingv1.NetworkPolicyPeer) {
+		for _, cidr := range cidrs {
+			peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+
+		return
+	}
+
+	inNamespace := func(ns string) []networkingv1.NetworkPolicyPeer {
+		return []networkingv1.NetworkPolicyPeer{{NamespaceSelector: namespaceSelector(ns)}}
+	}
+
+	withURL := func(u string) *deploymentv1alpha1.Cluster {
+		app := bot.DeepCopy()
+		app.Spec.Config.RedisURL = u
+
+		return app
+	}
+
+	selected := redisService("data", corev1.ServicePort{Port: 6379})
+	selected.Spec.Selector = map[string]string{"app": "redis"}
+
+	redisMaster := redisService("data", corev1.ServicePort{Port: 6379})
+	redisMaster.Name = "redis-master"
+
+	for _, test := range []struct {
+		name      string
+		app       *deploymentv1alpha1.Cluster
+		lookup    func(context.Context, string) ([]string, error)
+		objects   []client.Object
+		expect    []networkingv1.NetworkPolicyPeer
+		expectErr bool
+	}{
+		{"ipv4", withURL("10.1.2.3:6379"), nil, nil, ipBlocks("10.1.2.3/32"), false},
+		{"ipv6", withURL("redis://[fd00::1]:6379"), nil, nil, ipBlocks("fd00::1/128"), false},
+		{"service name", withURL("redis-master:6379"), nil, nil, inNamespace("testing"), false},
+		{"service and namespace", withURL("redis-master.data:6379"), nil, []client.Object{redisMaster}, inNamespace("data"), false},
+		{"two label external host", withURL("redis.example:6379"), lookup, []client.Object{redisMaster}, ipBlocks("10.0.0.21/32"), false},
+		{"service fqdn", withURL("redis://redis-master.data.svc.cluster.local:6379"), nil, nil, inNamespace("data"), false},
+		{"external", withURL("redis.example.com:6379"), lookup, nil, ipBlocks("10.0.0.11/32", "10.0.0.12/32"), false},
+		{"external without lookup", withURL("redis.example.com:6379"), nil, nil, nil, false},
+		{"external unresolvable", withURL("missing.example.com:6379"), lookup, nil, nil, true},
+		{"external without addresses", withURL("empty.example.com:6379"), lookup, nil, nil, true},
+		{"service ref", sharedRedisCluster(), nil, []client.Object{selected, redisPassword("data", "password")}, []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: namespaceSelector("data"),
+			PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "redis"}},
+		}}, false},
+		{"service ref without selector", sharedRedisCluster(), nil, []client.Object{redisService("data", corev1.ServicePort{Port: 6379}), redisPassword("data", "password")}, inNamespace("data"), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := &ClusterReconciler{
+				Client:           fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(test.objects...).Build(),
+				LookupHost:       test.lookup,
+				SharedNamespaces: []string{"data"},
+			}
+
+			received, err := r.resolveRedis(context.Background(), test.app)
+			if err == nil && test.expectErr {
+				t.Fatalf("expected error")
+			} else if err != nil && !test.expectErr {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if test.expectErr {
+				return
+			}
+
+			if !reflect.DeepEqual(test.expect, received.peers) {
+				t.Errorf("expected %#v, received %#v", test.expect, received.peers)
+			}
+		})
+	}
+}
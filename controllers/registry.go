@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// registryExpiresAnnotation records when the token held by a
+	// generated pull secret expires
+	registryExpiresAnnotation = "app.gec/registry-token-expires"
+
+	// registryRefreshMargin is how long before expiry a token is replaced
+	registryRefreshMargin = 10 * time.Minute
+)
+
+// now is overridden in tests
+var now = time.Now
+
+func registrySecretName(app *deploymentv1alpha1.Cluster) string {
+	return fmt.Sprintf("%s-registry", app.Name)
+}
+
+// RegistrySecret creates and refreshes the image pull secret described by
+// a Cluster's spec.registry
+func RegistrySecret(ctx context.Context, c client.Client, s *runtime.Scheme, app *deploymentv1alpha1.Cluster, ca deploymentv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
+	if app.Spec.Registry == nil {
+		return
+	}
+
+	found := &corev1.Secret{}
+
+	err = c.Get(ctx, types.NamespacedName{Name: registrySecretName(app), Namespace: app.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return
+	}
+
+	notFound := errors.IsNotFound(err)
+
+	// Rendered manifests are built without the operator's config, and
+	// never hold a token anyway
+	if !rendering(ctx) {
+		err = registryTokensFrom(ctx).check(app.Spec.Registry)
+		if err != nil {
+			return
+		}
+	}
+
+	// Tokens are only fetched when the current one is close to expiry;
+	// static credentials are cheap to read and so always are
+	if !notFound && app.Spec.Registry.SecretRef == nil && registryRefreshIn(found) > 0 {
+		return 0, nil
+	}
+
+	// Plans show the secret would be written without fetching a token,
+	// which would call out to ECR, STS or GCE's metadata server
+	if planned(ctx) && app.Spec.Registry.SecretRef == nil {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: registrySecretName(app), Namespace: app.Namespace}}
+		if notFound {
+			return 0, c.Create(ctx, secret)
+		}
+
+		return 0, c.Update(ctx, secret)
+	}
+
+	tokenCtx, span := startSpan(ctx, "Fetch registry token")
+	token, err := newTokenSource(c, app).Token(tokenCtx)
+	endSpan(span, err)
+
+	if err != nil {
+		return
+	}
+
+	secret, err := registrySecret(app, labels, token)
+	if err != nil {
+		return
+	}
+
+	err = ctrl.SetControllerReference(app, secret, s)
+	if err != nil {
+		return
+	}
+
+	if notFound {
+		err = c.Create(ctx, secret)
+
+		return
+	}
+
+	if !reflect.DeepEqual(found.Data, secret.Data) || !reflect.DeepEqual(found.Annotations, secret.Annotations) {
+		secret.ResourceVersion = found.ResourceVersion
+
+		err = c.Update(ctx, secret)
+	}
+
+	return
+}
+
+func registrySecret(app *deploymentv1alpha1.Cluster, labels map[string]string, token registryToken) (*corev1.Secret, error) {
+	cfg, err := dockerConfigJSON(app.Spec.Registry.Server, token)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registrySecretName(app),
+			Namespace: app.Namespace,
+			Labels:    labels,
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: cfg,
+		},
+	}
+
+	if !token.Expires.IsZero() {
+		secret.Annotations = map[string]string{
+			registryExpiresAnnotation: token.Expires.UTC().Format(time.RFC3339),
+		}
+	}
+
+	return secret, nil
+}
+
+func dockerConfigJSON(server string, token registryToken) ([]byte, error) {
+	type auth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+
+	return json.Marshal(map[string]map[string]auth{
+		"auths": {
+			server: {
+				Username: token.Username,
+				Password: token.Password,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(token.Username + ":" + token.Password)),
+			},
+		},
+	})
+}
+
+// registryRefreshIn returns how long until a generated pull secret's token
+// should be replaced, or zero where it should be replaced now.
+//
+// Secrets with no expiry never need refreshing, and so return a
+// negative duration
+func registryRefreshIn(secret *corev1.Secret) time.Duration {
+	v, ok := secret.Annotations[registryExpiresAnnotation]
+	if !ok {
+		return -1
+	}
+
+	expires, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0
+	}
+
+	d := expires.Add(-registryRefreshMargin).Sub(now())
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+// RegistryRefreshIn returns when a Cluster next needs reconciling in
+// order to refresh its pull secret, or zero if it never does
+func (r *ClusterReconciler) RegistryRefreshIn(ctx context.Context, app *deploymentv1alpha1.Cluster) time.Duration {
+	if app.Spec.Registry == nil {
+		return 0
+	}
+
+	found := &corev1.Secret{}
+
+	err := r.Get(ctx, types.NamespacedName{Name: registrySecretName(app), Namespace: app.Namespace}, found)
+	if err != nil {
+		return time.Minute
+	}
+
+	d := registryRefreshIn(found)
+	switch {
+	case d < 0:
+		return 0
+
+	case d == 0:
+		return time.Second
+
+	default:
+		return d
+	}
+}
@@ -0,0 +1,371 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var testNow = time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+func registryCluster(reg *deploymentv1alpha1.RegistryCredentials) *deploymentv1alpha1.Cluster {
+	app := bot.DeepCopy()
+	app.Spec.Registry = reg
+
+	return app
+}
+
+// ecrTokens allows the ECR registry the tests use
+var ecrTokens = RegistryTokens{ECR: true, Servers: []string{"123456789012.dkr.ecr.eu-west-2.amazonaws.com"}}
+
+func stubTokenSource(t *testing.T, token registryToken, calls *int) {
+	t.Helper()
+
+	orig := newTokenSource
+	origNow := now
+
+	t.Cleanup(func() {
+		newTokenSource = orig
+		now = origNow
+	})
+
+	now = func() time.Time { return testNow }
+	newTokenSource = func(client.Client, *deploymentv1alpha1.Cluster) tokenSource {
+		return tokenSourceFunc(func(context.Context) (registryToken, error) {
+			*calls++
+
+			return token, nil
+		})
+	}
+}
+
+func TestRegistrySecret(t *testing.T) {
+	app := registryCluster(&deploymentv1alpha1.RegistryCredentials{
+		Server: "123456789012.dkr.ecr.eu-west-2.amazonaws.com",
+		ECR:    &deploymentv1alpha1.ECRTokenSource{Region: "eu-west-2"},
+	})
+
+	for _, test := range []struct {
+		name        string
+		expires     time.Time
+		expectCalls int
+	}{
+		{"creates and keeps a fresh token", testNow.Add(12 * time.Hour), 1},
+		{"refreshes a token close to expiry", testNow.Add(5 * time.Minute), 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int
+			stubTokenSource(t, registryToken{Username: "AWS", Password: "hunter2", Expires: test.expires}, &calls)
+
+			c := fake.NewClientBuilder().WithScheme(testScheme(t)).Build()
+			ctx := context.WithValue(context.Background(), "registry", ecrTokens)
+
+			for i := 0; i < 2; i++ {
+				_, err := RegistrySecret(ctx, c, testScheme(t), app, deploymentv1alpha1.ClusterMeta, GecMetaLabels(app), nil)
+				if err != nil {
+					t.Fatalf("unexpected error: %#v", err)
+				}
+			}
+
+			if calls != test.expectCalls {
+				t.Errorf("expected %d token fetches, received %d", test.expectCalls, calls)
+			}
+
+			secret := new(corev1.Secret)
+
+			err := c.Get(context.Background(), types.NamespacedName{Name: "my-test-cluster-registry", Namespace: "testing"}, secret)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if secret.Type != corev1.SecretTypeDockerConfigJson {
+				t.Errorf("unexpected type %q", secret.Type)
+			}
+
+			cfg := struct {
+				Auths map[string]struct {
+					Auth string `json:"auth"`
+				} `json:"auths"`
+			}{}
+
+			err = json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			expect := base64.StdEncoding.EncodeToString([]byte("AWS:hunter2"))
+			if received := cfg.Auths[app.Spec.Registry.Server].Auth; received != expect {
+				t.Errorf("expected auth %q, received %q", expect, received)
+			}
+		})
+	}
+}
+
+func TestRegistrySecret_Unset(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).Build()
+
+	_, err := RegistrySecret(context.Background(), c, testScheme(t), bot, deploymentv1alpha1.ClusterMeta, GecMetaLabels(bot), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	err = c.Get(context.Background(), types.NamespacedName{Name: "my-test-cluster-registry", Namespace: "testing"}, new(corev1.Secret))
+	if err == nil {
+		t.Errorf("expected no secret to be created")
+	}
+}
+
+func TestRegistryTokens_Check(t *testing.T) {
+	const server = "123456789012.dkr.ecr.eu-west-2.amazonaws.com"
+
+	ecr := &deploymentv1alpha1.RegistryCredentials{Server: server, ECR: &deploymentv1alpha1.ECRTokenSource{Region: "eu-west-2"}}
+	gcr := &deploymentv1alpha1.RegistryCredentials{Server: "europe-docker.pkg.dev", GCR: &deploymentv1alpha1.GCRTokenSource{}}
+	secret := &deploymentv1alpha1.RegistryCredentials{Server: "ghcr.io", SecretRef: &deploymentv1alpha1.RegistrySecretRef{Name: "ghcr"}}
+
+	for _, test := range []struct {
+		name        string
+		tokens      RegistryTokens
+		reg         *deploymentv1alpha1.RegistryCredentials
+		expectError bool
+	}{
+		{"no registry", RegistryTokens{}, nil, false},
+		{"secret", RegistryTokens{}, secret, false},
+		{"ecr disabled", RegistryTokens{Servers: []string{server}}, ecr, true},
+		{"ecr allowed", ecrTokens, ecr, false},
+		{"ecr unlisted server", RegistryTokens{ECR: true, Servers: []string{"210987654321.dkr.ecr.eu-west-2.amazonaws.com"}}, ecr, true},
+		{"gcr disabled", ecrTokens, gcr, true},
+		{"gcr allowed", RegistryTokens{GCR: true, Servers: []string{"europe-docker.pkg.dev"}}, gcr, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.tokens.check(test.reg)
+			if err == nil && test.expectError {
+				t.Errorf("expected error")
+			} else if err != nil && !test.expectError {
+				t.Errorf("unexpected error: %#v", err)
+			}
+		})
+	}
+}
+
+func TestRegistrySecret_Refused(t *testing.T) {
+	app := registryCluster(&deploymentv1alpha1.RegistryCredentials{
+		Server: "123456789012.dkr.ecr.eu-west-2.amazonaws.com",
+		ECR:    &deploymentv1alpha1.ECRTokenSource{Region: "eu-west-2"},
+	})
+
+	var calls int
+	stubTokenSource(t, registryToken{Username: "AWS", Password: "hunter2", Expires: testNow.Add(12 * time.Hour)}, &calls)
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).Build()
+
+	_, err := RegistrySecret(context.Background(), c, testScheme(t), app, deploymentv1alpha1.ClusterMeta, GecMetaLabels(app), nil)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if calls != 0 {
+		t.Errorf("expected no token fetches, received %d", calls)
+	}
+
+	err = c.Get(context.Background(), types.NamespacedName{Name: "my-test-cluster-registry", Namespace: "testing"}, new(corev1.Secret))
+	if err == nil {
+		t.Errorf("expected no secret to be created")
+	}
+}
+
+func TestRegistrySecret_Plan(t *testing.T) {
+	app := registryCluster(&deploymentv1alpha1.RegistryCredentials{
+		Server: "123456789012.dkr.ecr.eu-west-2.amazonaws.com",
+		ECR:    &deploymentv1alpha1.ECRTokenSource{Region: "eu-west-2"},
+	})
+
+	var calls int
+	stubTokenSource(t, registryToken{Username: "AWS", Password: "hunter2", Expires: testNow.Add(12 * time.Hour)}, &calls)
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).Build()
+	p := newPlanner(c, app)
+
+	ctx := context.WithValue(context.WithValue(context.Background(), "plan", p), "registry", ecrTokens)
+
+	_, err := RegistrySecret(ctx, p, testScheme(t), app, deploymentv1alpha1.ClusterMeta, GecMetaLabels(app), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	if calls != 0 {
+		t.Errorf("expected no token fetches, received %d", calls)
+	}
+
+	if len(p.changes) != 1 || p.changes[0].Kind != "Secret" || p.changes[0].Action != deploymentv1alpha1.PlanCreate {
+		t.Errorf("expected the secret's creation to be planned, received %#v", p.changes)
+	}
+}
+
+func TestServiceAccount_Registry(t *testing.T) {
+	app := registryCluster(&deploymentv1alpha1.RegistryCredentials{
+		Server: "ghcr.io",
+		SecretRef: &deploymentv1alpha1.RegistrySecretRef{
+			Name: "ghcr",
+		},
+	})
+
+	received := serviceAccount(app, deploymentv1alpha1.ClusterBot, nil).ImagePullSecrets
+	expect := []corev1.LocalObjectReference{{Name: "ecr-pull"}, {Name: "my-test-cluster-registry"}}
+
+	if fmt.Sprint(received) != fmt.Sprint(expect) {
+		t.Errorf("expected %v, received %v", expect, received)
+	}
+
+	// The cluster's own identity must not be modified
+	if len(app.Spec.Identity.ImagePullSecrets) != 1 {
+		t.Errorf("cluster identity modified: %v", app.Spec.Identity.ImagePullSecrets)
+	}
+}
+
+func TestRegistryRefreshIn(t *testing.T) {
+	orig := now
+	t.Cleanup(func() { now = orig })
+
+	now = func() time.Time { return testNow }
+
+	for _, test := range []struct {
+		name        string
+		annotations map[string]string
+		expect      time.Duration
+	}{
+		{"static credentials", nil, -1},
+		{"valid token", map[string]string{registryExpiresAnnotation: testNow.Add(time.Hour).Format(time.RFC3339)}, 50 * time.Minute},
+		{"within margin", map[string]string{registryExpiresAnnotation: testNow.Add(time.Minute).Format(time.RFC3339)}, 0},
+		{"garbage", map[string]string{registryExpiresAnnotation: "soon"}, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			secret := new(corev1.Secret)
+			secret.Annotations = test.annotations
+
+			received := registryRefreshIn(secret)
+			if received != test.expect {
+				t.Errorf("expected %s, received %s", test.expect, received)
+			}
+		})
+	}
+}
+
+func TestSecretTokenSource(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		secret    *corev1.Secret
+		expectErr bool
+	}{
+		{"missing secret", testSecret("other", nil), true},
+		{"missing key", testSecret("ghcr", map[string]string{"username": "gec"}), true},
+		{"valid", testSecret("ghcr", map[string]string{"username": "gec", "password": "hunter2"}), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(test.secret).Build()
+
+			ts := secretTokenSource{c: c, namespace: "testing", ref: deploymentv1alpha1.RegistrySecretRef{Name: "ghcr"}}
+
+			token, err := ts.Token(context.Background())
+			if err == nil && test.expectErr {
+				t.Errorf("expected error")
+			} else if err != nil && !test.expectErr {
+				t.Errorf("unexpected error: %#v", err)
+			}
+
+			if !test.expectErr && (token.Username != "gec" || token.Password != "hunter2" || !token.Expires.IsZero()) {
+				t.Errorf("unexpected token %#v", token)
+			}
+		})
+	}
+}
+
+func TestGCRTokenSource(t *testing.T) {
+	orig := now
+	t.Cleanup(func() { now = orig })
+
+	now = func() time.Time { return testNow }
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		fmt.Fprint(w, `{"access_token":"ya29.abc","expires_in":3600,"token_type":"Bearer"}`)
+	}))
+	defer srv.Close()
+
+	token, err := gcrTokenSource{url: srv.URL, client: srv.Client()}.Token(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	expect := registryToken{Username: gcpTokenUsername, Password: "ya29.abc", Expires: testNow.Add(time.Hour)}
+	if token != expect {
+		t.Errorf("expected %#v, received %#v", expect, token)
+	}
+}
+
+func TestECRTokenSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		fmt.Fprintf(w, `{"authorizationData":[{"authorizationToken":%q,"expiresAt":1659398400}]}`,
+			base64.StdEncoding.EncodeToString([]byte("AWS:hunter2")),
+		)
+	}))
+	defer srv.Close()
+
+	ts := ecrTokenSource{
+		region: "eu-west-2",
+		options: []func(*ecr.Options){func(o *ecr.Options) {
+			o.Credentials = credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "session")
+			o.EndpointResolver = ecr.EndpointResolverFromURL(srv.URL)
+		}},
+	}
+
+	token, err := ts.Token(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	expect := registryToken{Username: "AWS", Password: "hunter2", Expires: time.Unix(1659398400, 0)}
+	if token.Username != expect.Username || token.Password != expect.Password || !token.Expires.Equal(expect.Expires) {
+		t.Errorf("expected %#v, received %#v", expect, token)
+	}
+}
+
+func TestECRTokenSource_NoCredentials(t *testing.T) {
+	for _, k := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_PROFILE"} {
+		t.Setenv(k, "")
+	}
+
+	t.Setenv("AWS_CONFIG_FILE", "testdata/does-not-exist")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "testdata/does-not-exist")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	_, err := ecrTokenSource{region: "eu-west-2"}.Token(context.Background())
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
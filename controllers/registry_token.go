@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	gcpTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+	// gcpTokenUsername is the username GCR and Artifact Registry expect
+	// alongside an access token
+	gcpTokenUsername = "oauth2accesstoken"
+)
+
+// metadataClient fetches tokens from GCE's metadata server, which answers
+// quickly or not at all
+var metadataClient = &http.Client{Timeout: 10 * time.Second}
+
+// RegistryTokens allowlists the ECR and GCR tokens Clusters may have the
+// operator fetch. Both are fetched with the operator's own cloud
+// credentials, which any Cluster could otherwise have written into a pull
+// secret in its namespace, so the zero value allows neither
+type RegistryTokens struct {
+	// ECR and GCR enable fetching tokens from each
+	ECR bool
+	GCR bool
+
+	// Servers are the registries tokens may be fetched for
+	Servers []string
+}
+
+// check returns an error where reg would have the operator fetch a token
+// it isn't allowed to. Credentials from Secrets are always allowed
+func (t RegistryTokens) check(reg *deploymentv1alpha1.RegistryCredentials) error {
+	var source string
+
+	switch {
+	case reg == nil || reg.SecretRef != nil:
+		return nil
+
+	case reg.ECR != nil && !t.ECR:
+		source = "ecr"
+
+	case reg.GCR != nil && !t.GCR:
+		source = "gcr"
+
+	default:
+		for _, s := range t.Servers {
+			if s == reg.Server {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("registry %q: the operator isn't allowed to fetch tokens for this server", reg.Server)
+	}
+
+	return fmt.Errorf("registry %q: the operator isn't allowed to fetch %s tokens", reg.Server, source)
+}
+
+// registryTokensFrom returns the registry tokens allowed during a
+// reconcile
+func registryTokensFrom(ctx context.Context) RegistryTokens {
+	t, _ := ctx.Value("registry").(RegistryTokens)
+
+	return t
+}
+
+// registryToken is a set of registry credentials. Static credentials
+// have a zero Expires
+type registryToken struct {
+	Username string
+	Password string
+	Expires  time.Time
+}
+
+type tokenSource interface {
+	Token(context.Context) (registryToken, error)
+}
+
+type tokenSourceFunc func(context.Context) (registryToken, error)
+
+func (f tokenSourceFunc) Token(ctx context.Context) (registryToken, error) {
+	return f(ctx)
+}
+
+// newTokenSource returns the tokenSource for a Cluster's registry
+// credentials. It is a variable so that tests can avoid calling out to
+// real cloud providers
+var newTokenSource = func(c client.Client, app *deploymentv1alpha1.Cluster) tokenSource {
+	reg := app.Spec.Registry
+
+	switch {
+	case reg.SecretRef != nil:
+		return secretTokenSource{c: c, namespace: app.Namespace, ref: *reg.SecretRef}
+
+	case reg.ECR != nil:
+		return ecrTokenSource{region: reg.ECR.Region}
+
+	case reg.GCR != nil:
+		return gcrTokenSource{url: gcpTokenURL, client: metadataClient}
+
+	default:
+		return tokenSourceFunc(func(context.Context) (registryToken, error) {
+			return registryToken{}, fmt.Errorf("registry %q: no credentials source set", reg.Server)
+		})
+	}
+}
+
+// secretTokenSource reads static credentials from a Secret
+type secretTokenSource struct {
+	c         client.Client
+	namespace string
+	ref       deploymentv1alpha1.RegistrySecretRef
+}
+
+func (s secretTokenSource) Token(ctx context.Context) (t registryToken, err error) {
+	secret := new(corev1.Secret)
+
+	err = s.c.Get(ctx, types.NamespacedName{Name: s.ref.Name, Namespace: s.namespace}, secret)
+	if err != nil {
+		return
+	}
+
+	uk, pk := s.ref.Keys()
+
+	if _, ok := secret.Data[uk]; !ok {
+		return t, fmt.Errorf("registry secret %q has no key %q", s.ref.Name, uk)
+	}
+
+	if _, ok := secret.Data[pk]; !ok {
+		return t, fmt.Errorf("registry secret %q has no key %q", s.ref.Name, pk)
+	}
+
+	return registryToken{
+		Username: string(secret.Data[uk]),
+		Password: string(secret.Data[pk]),
+	}, nil
+}
+
+// gcrTokenSource fetches an access token for the operator's own GCP
+// service account from the metadata server
+type gcrTokenSource struct {
+	url    string
+	client *http.Client
+}
+
+func (g gcrTokenSource) Token(ctx context.Context) (t registryToken, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url, nil)
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	body, err := do(g.client, req)
+	if err != nil {
+		return
+	}
+
+	tok := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+
+	err = json.Unmarshal(body, &tok)
+	if err != nil {
+		return
+	}
+
+	return registryToken{
+		Username: gcpTokenUsername,
+		Password: tok.AccessToken,
+		Expires:  now().Add(time.Duration(tok.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// ecrTokenSource fetches an ECR authorization token using the operator's
+// own AWS credentials, found by the SDK's default chain. This covers
+// static credentials in the environment as well as IRSA's web identity
+// token
+type ecrTokenSource struct {
+	region string
+
+	// options are overridden in tests
+	options []func(*ecr.Options)
+}
+
+func (e ecrTokenSource) Token(ctx context.Context) (t registryToken, err error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(e.region))
+	if err != nil {
+		return
+	}
+
+	out, err := ecr.NewFromConfig(cfg, e.options...).GetAuthorizationToken(ctx, new(ecr.GetAuthorizationTokenInput))
+	if err != nil {
+		return
+	}
+
+	if len(out.AuthorizationData) == 0 {
+		return t, fmt.Errorf("ecr returned no authorization data")
+	}
+
+	data := out.AuthorizationData[0]
+
+	decoded, err := base64.StdEncoding.DecodeString(aws.ToString(data.AuthorizationToken))
+	if err != nil {
+		return
+	}
+
+	user, pass, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return t, fmt.Errorf("ecr returned a malformed authorization token")
+	}
+
+	return registryToken{
+		Username: user,
+		Password: pass,
+		Expires:  aws.ToTime(data.ExpiresAt),
+	}, nil
+}
+
+func do(c *http.Client, req *http.Request) (body []byte, err error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return
+	}
+
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode >= 300 {
+		err = fmt.Errorf("%s %s: unexpected status %s", req.Method, req.URL.Host, resp.Status)
+	}
+
+	return
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Render returns every object the operator would create for a Cluster,
+// were none of them there yet, by running the reconciler's own upserters
+// against a planner. Apps are built with d.
+//
+// objects are served to upserters as though they were in the cluster, such
+// as Secrets an app's credentials reference. The registry pull secret holds
+// live credentials, and so is left out
+func Render(ctx context.Context, s *runtime.Scheme, d appv1alpha1.Defaults, app *appv1alpha1.Cluster, objects ...client.Object) ([]client.Object, error) {
+	reader, err := newObjectReader(s, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ClusterReconciler{Client: reader, Scheme: s, Defaults: &d}
+
+	p := newPlanner(reader, app)
+	p.rendering = true
+
+	err = r.planApps(ctx, app, p)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range p.written {
+		gvk, err := apiutil.GVKForObject(o, s)
+		if err != nil {
+			return nil, err
+		}
+
+		o.GetObjectKind().SetGroupVersionKind(gvk)
+
+		// References to a Cluster which was never created, and so has no
+		// UID, would be rejected by the API server
+		if app.UID == "" {
+			o.SetOwnerReferences(nil)
+		}
+	}
+
+	return p.written, nil
+}
+
+// rendering returns whether upserters are being run by Render
+func rendering(ctx context.Context) bool {
+	p, ok := ctx.Value("plan").(*planner)
+
+	return ok && p.rendering
+}
+
+type objectKey struct {
+	gvk schema.GroupVersionKind
+	key client.ObjectKey
+}
+
+// objectReader is a client which serves a fixed set of objects, and
+// supports nothing but reading them back
+type objectReader struct {
+	client.Client
+
+	scheme  *runtime.Scheme
+	objects map[objectKey]client.Object
+}
+
+func newObjectReader(s *runtime.Scheme, objects []client.Object) (*objectReader, error) {
+	o := &objectReader{
+		scheme:  s,
+		objects: make(map[objectKey]client.Object, len(objects)),
+	}
+
+	for _, obj := range objects {
+		gvk, err := apiutil.GVKForObject(obj, s)
+		if err != nil {
+			return nil, err
+		}
+
+		o.objects[objectKey{gvk, client.ObjectKeyFromObject(obj)}] = obj
+	}
+
+	return o, nil
+}
+
+func (o *objectReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, o.scheme)
+	if err != nil {
+		return err
+	}
+
+	found, ok := o.objects[objectKey{gvk, key}]
+	if !ok {
+		return errors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, key.Name)
+	}
+
+	dst, src := reflect.ValueOf(obj), reflect.ValueOf(found.DeepCopyObject())
+	if dst.Type() != src.Type() {
+		return fmt.Errorf("%s %s is a %T, not a %T", gvk.Kind, key, found, obj)
+	}
+
+	dst.Elem().Set(src.Elem())
+
+	return nil
+}
+
+func (o *objectReader) Scheme() *runtime.Scheme {
+	return o.scheme
+}
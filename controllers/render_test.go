@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	deploymentv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRender(t *testing.T) {
+	app := bot.DeepCopy()
+	app.Spec.Registry = &deploymentv1alpha1.RegistryCredentials{
+		Server:    "registry.example.com",
+		SecretRef: &deploymentv1alpha1.RegistrySecretRef{Name: "registry"},
+	}
+
+	out, err := Render(context.Background(), testScheme(t), deploymentv1alpha1.BuiltinDefaults(), app)
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	var received []string
+	for _, o := range out {
+		received = append(received, o.GetObjectKind().GroupVersionKind().Kind+"/"+o.GetName())
+
+		if len(o.GetOwnerReferences()) > 0 {
+			t.Errorf("%s: expected no owner references", o.GetName())
+		}
+	}
+
+	// Everything the reconciler creates, in reconcile order, bar the pull
+	// secret
+	expect := []string{
+		"ConfigMap/my-test-cluster-meta",
+		"NetworkPolicy/my-test-cluster-meta",
+		"ServiceAccount/my-test-cluster-gec-bot",
+		"ConfigMap/my-test-cluster-gec-bot",
+		"PersistentVolumeClaim/my-test-cluster-gec-bot",
+		"Deployment/my-test-cluster-gec-bot",
+		"PodDisruptionBudget/my-test-cluster-gec-bot",
+		"NetworkPolicy/my-test-cluster-gec-bot",
+		"ServiceAccount/my-test-cluster-gec-processor",
+		"ConfigMap/my-test-cluster-gec-processor",
+		"Deployment/my-test-cluster-gec-processor",
+		"PodDisruptionBudget/my-test-cluster-gec-processor",
+		"NetworkPolicy/my-test-cluster-gec-processor",
+		"ServiceAccount/my-test-cluster-gec-slacker",
+		"ConfigMap/my-test-cluster-gec-slacker",
+		"Deployment/my-test-cluster-gec-slacker",
+		"PodDisruptionBudget/my-test-cluster-gec-slacker",
+		"NetworkPolicy/my-test-cluster-gec-slacker",
+	}
+
+	if !reflect.DeepEqual(expect, received) {
+		t.Errorf("expected %v, received %v", expect, received)
+	}
+}
+
+func TestRender_Credentials(t *testing.T) {
+	app := credentialledCluster()
+
+	for _, test := range []struct {
+		name        string
+		objects     []client.Object
+		expectError bool
+	}{
+		{"secrets not given", nil, true},
+		{"secrets given", []client.Object{
+			testSecret("whatsapp", map[string]string{"session": "s"}),
+			testSecret("slack", map[string]string{"token": "t", "signing-secret": "ss"}),
+		}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := Render(context.Background(), testScheme(t), deploymentv1alpha1.BuiltinDefaults(), app, test.objects...)
+			if err == nil && test.expectError {
+				t.Fatalf("expected error")
+			} else if err != nil && !test.expectError {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			for _, o := range out {
+				d, ok := o.(*appsv1.Deployment)
+				if !ok {
+					continue
+				}
+
+				if d.Name == app.InClusterName(deploymentv1alpha1.ClusterBot) && d.Spec.Template.Annotations[credentialsChecksumAnnotation] == "" {
+					t.Errorf("expected bot's credentials checksum to be rendered")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// UpdateStatus writes the observed state of each app's Deployment
+// into the Cluster's status, where it differs from what is already there
+func (r *ClusterReconciler) UpdateStatus(ctx context.Context, app *appv1alpha1.Cluster) (err error) {
+	apps, err := app.Apps()
+	if err != nil {
+		return
+	}
+
+	status := app.Status.DeepCopy()
+
+	// Components are listed afresh, dropping any no longer in the spec
+	status.Components = nil
+	status.Suspended = nil
+	status.Plan = nil
+	status.Phase = appv1alpha1.ClusterReady
+
+	for _, ca := range apps {
+		d := &appsv1.Deployment{}
+
+		err = r.Get(ctx, types.NamespacedName{Name: app.InClusterName(ca), Namespace: app.Namespace}, d)
+		if err != nil && !errors.IsNotFound(err) {
+			return
+		}
+
+		if errors.IsNotFound(err) {
+			*status.App(ca) = appv1alpha1.AppStatus{}
+			status.Phase = appv1alpha1.ClusterPending
+
+			continue
+		}
+
+		*status.App(ca) = appStatus(d)
+
+		if app.InClusterApp(ca).Suspended {
+			status.Suspended = append(status.Suspended, ca.String())
+		}
+
+		if !status.App(ca).Ready && status.Phase == appv1alpha1.ClusterReady {
+			status.Phase = appv1alpha1.ClusterProgressing
+		}
+	}
+
+	if app.Spec.Paused {
+		status.Phase = appv1alpha1.ClusterPaused
+	}
+
+	if redis, ok := ctx.Value("redis").(*resolvedRedis); ok {
+		status.RedisAddress = redis.addr
+	}
+
+	// Changes are only held back during a reconcile; otherwise whatever
+	// was last recorded stands
+	if m, ok := ctx.Value("maintenance").(*maintenance); ok {
+		status.PendingChanges = m.pendingChanges(status.PendingChanges)
+		status.NextMaintenanceWindow = nil
+
+		if len(status.PendingChanges) > 0 && !m.next.IsZero() {
+			// Times read back from the API server are local, so this
+			// compares equal with what's already there
+			next := metav1.NewTime(m.next.Local())
+			status.NextMaintenanceWindow = &next
+		}
+	}
+
+	if reflect.DeepEqual(app.Status, *status) {
+		return nil
+	}
+
+	app.Status = *status
+
+	return r.Status().Update(ctx, app)
+}
+
+// appStatus derives an AppStatus from a Deployment.
+//
+// ReadyReplicas only counts pods passing their readiness probe, so an app
+// is only ever reported ready once its probes say so
+func appStatus(d *appsv1.Deployment) appv1alpha1.AppStatus {
+	var desired int32 = 1
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+
+	return appv1alpha1.AppStatus{
+		Version:       d.Spec.Template.Labels["version"],
+		Replicas:      desired,
+		ReadyReplicas: d.Status.ReadyReplicas,
+		Ready: d.Status.ObservedGeneration >= d.Generation &&
+			d.Status.UpdatedReplicas >= desired &&
+			d.Status.ReadyReplicas >= desired,
+	}
+}
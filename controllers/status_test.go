@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+
+	s := runtime.NewScheme()
+
+	err := clientgoscheme.AddToScheme(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = appv1alpha1.AddToScheme(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func testDeployment(t *testing.T, generation, observed int64, replicas, updated, ready int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "my-test-cluster-gec-bot",
+			Namespace:  "testing",
+			Generation: generation,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: mustDeployment(t, bot, appv1alpha1.ClusterBot, GecBotLabels(bot), GecBotSelectors(bot)).Spec.Template,
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: observed,
+			UpdatedReplicas:    updated,
+			ReadyReplicas:      ready,
+		},
+	}
+}
+
+func TestAppStatus(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		d           *appsv1.Deployment
+		expectReady bool
+	}{
+		{"ready", testDeployment(t, 1, 1, 1, 1, 1), true},
+		{"failing readiness probe", testDeployment(t, 1, 1, 1, 1, 0), false},
+		{"rolling out", testDeployment(t, 2, 2, 1, 0, 1), false},
+		{"generation not yet observed", testDeployment(t, 2, 1, 1, 1, 1), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			received := appStatus(test.d)
+			if test.expectReady != received.Ready {
+				t.Errorf("expected %v, received %v", test.expectReady, received.Ready)
+			}
+
+			if received.Version != "v0.0.1" {
+				t.Errorf("expected %q, received %q", "v0.0.1", received.Version)
+			}
+		})
+	}
+}
+
+func TestClusterReconciler_UpdateStatus(t *testing.T) {
+	app := bot.DeepCopy()
+
+	c := fake.NewClientBuilder().
+		WithScheme(testScheme(t)).
+		WithObjects(app, testDeployment(t, 1, 1, 1, 1, 1)).
+		Build()
+
+	r := &ClusterReconciler{Client: c, Scheme: c.Scheme()}
+
+	err := r.UpdateStatus(context.Background(), app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !app.Status.Bot.Ready {
+		t.Errorf("expected bot to be ready")
+	}
+
+	if app.Status.Processor.Ready {
+		t.Errorf("expected processor to not be ready")
+	}
+}
+
+func TestClusterReconciler_UpdateStatus_Phase(t *testing.T) {
+	deploymentFor := func(ca appv1alpha1.ClusterApp, ready int32) *appsv1.Deployment {
+		d := testDeployment(t, 1, 1, 1, 1, ready)
+		d.Name = bot.InClusterName(ca)
+
+		return d
+	}
+
+	for _, test := range []struct {
+		name        string
+		deployments []*appsv1.Deployment
+		expect      appv1alpha1.ClusterPhase
+	}{
+		{"nothing deployed", nil, appv1alpha1.ClusterPending},
+		{"partly deployed", []*appsv1.Deployment{
+			deploymentFor(appv1alpha1.ClusterBot, 1),
+			deploymentFor(appv1alpha1.ClusterProcessor, 0),
+		}, appv1alpha1.ClusterPending},
+		{"not yet ready", []*appsv1.Deployment{
+			deploymentFor(appv1alpha1.ClusterBot, 1),
+			deploymentFor(appv1alpha1.ClusterProcessor, 0),
+			deploymentFor(appv1alpha1.ClusterSlacker, 1),
+		}, appv1alpha1.ClusterProgressing},
+		{"ready", []*appsv1.Deployment{
+			deploymentFor(appv1alpha1.ClusterBot, 1),
+			deploymentFor(appv1alpha1.ClusterProcessor, 1),
+			deploymentFor(appv1alpha1.ClusterSlacker, 1),
+		}, appv1alpha1.ClusterReady},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			app := bot.DeepCopy()
+
+			b := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app)
+			for _, d := range test.deployments {
+				b = b.WithObjects(d)
+			}
+
+			c := b.Build()
+			r := &ClusterReconciler{Client: c, Scheme: c.Scheme()}
+
+			err := r.UpdateStatus(context.Background(), app)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.expect != app.Status.Phase {
+				t.Errorf("expected %q, received %q", test.expect, app.Status.Phase)
+			}
+		})
+	}
+}
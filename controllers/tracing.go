@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	goruntime "runtime"
+	"strings"
+	"time"
+
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// tracerName names the instrumentation spans come from
+const tracerName = "github.com/gender-equality-community/gec-operator/controllers"
+
+// Span attributes
+const (
+	clusterNameKey      = attribute.Key("gec.cluster.name")
+	clusterNamespaceKey = attribute.Key("gec.cluster.namespace")
+	appKey              = attribute.Key("gec.app")
+	objectKindKey       = attribute.Key("k8s.object.kind")
+	objectNameKey       = attribute.Key("k8s.object.name")
+	objectNamespaceKey  = attribute.Key("k8s.namespace.name")
+)
+
+// withTraceAttributes returns a copy of ctx whose spans are tagged with
+// attrs, as well as any attributes ctx already tags spans with
+func withTraceAttributes(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	existing, _ := ctx.Value("trace").([]attribute.KeyValue)
+
+	return context.WithValue(ctx, "trace", append(append([]attribute.KeyValue{}, existing...), attrs...))
+}
+
+// startSpan starts a span, tagged with the Cluster and app being
+// reconciled. Spans go nowhere unless the manager configures an exporter
+func startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	attrs, _ := ctx.Value("trace").([]attribute.KeyValue)
+
+	return otel.Tracer(tracerName).Start(ctx, name, append(opts, trace.WithAttributes(attrs...))...)
+}
+
+// endSpan ends a span, marking it failed where err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// upsert runs an upserter within a span of its own
+func (r *ClusterReconciler) upsert(ctx context.Context, f upserter, app *appv1alpha1.Cluster, ca appv1alpha1.ClusterApp, labels, selectors map[string]string) (requeue time.Duration, err error) {
+	ctx, span := startSpan(ctx, upserterName(f))
+	defer func() { endSpan(span, err) }()
+
+	return f(ctx, r.writer(ctx), r.Scheme, app, ca, labels, selectors)
+}
+
+// upserterName returns the name of an upserter's function, such as
+// Deployment
+func upserterName(f upserter) string {
+	fn := goruntime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "upserter"
+	}
+
+	name := fn.Name()
+
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// tracingClient wraps a client, starting a span for each API call
+type tracingClient struct {
+	client.Client
+}
+
+// NewTracingClient returns a client which traces each API call c makes
+func NewTracingClient(c client.Client) client.Client {
+	return tracingClient{Client: c}
+}
+
+func (c tracingClient) start(ctx context.Context, verb string, obj client.Object) (context.Context, trace.Span) {
+	return startObjectSpan(ctx, c.Scheme(), verb, obj, "")
+}
+
+func (c tracingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) (err error) {
+	ctx, span := c.start(ctx, "Get", obj)
+	span.SetAttributes(objectNameKey.String(key.Name), objectNamespaceKey.String(key.Namespace))
+
+	defer func() { endSpan(span, err) }()
+
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c tracingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (err error) {
+	kind := "object"
+	if gvk, gvkErr := apiutil.GVKForObject(list, c.Scheme()); gvkErr == nil {
+		kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+
+	ctx, span := startSpan(ctx, "List "+kind, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(objectKindKey.String(kind)))
+	defer func() { endSpan(span, err) }()
+
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c tracingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) (err error) {
+	ctx, span := c.start(ctx, "Create", obj)
+	defer func() { endSpan(span, err) }()
+
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c tracingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) (err error) {
+	ctx, span := c.start(ctx, "Update", obj)
+	defer func() { endSpan(span, err) }()
+
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c tracingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) (err error) {
+	ctx, span := c.start(ctx, "Patch", obj)
+	defer func() { endSpan(span, err) }()
+
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c tracingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) (err error) {
+	ctx, span := c.start(ctx, "Delete", obj)
+	defer func() { endSpan(span, err) }()
+
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c tracingClient) Status() client.StatusWriter {
+	return tracingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+// tracingStatusWriter traces writes to objects' status
+type tracingStatusWriter struct {
+	client.StatusWriter
+
+	client tracingClient
+}
+
+func (w tracingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) (err error) {
+	ctx, span := startObjectSpan(ctx, w.client.Scheme(), "Update", obj, "status")
+	defer func() { endSpan(span, err) }()
+
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w tracingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) (err error) {
+	ctx, span := startObjectSpan(ctx, w.client.Scheme(), "Patch", obj, "status")
+	defer func() { endSpan(span, err) }()
+
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+// startObjectSpan starts a span for an API call on a single object, named
+// for the call and the object's kind, such as Get Deployment or Update
+// Cluster/status
+func startObjectSpan(ctx context.Context, s *runtime.Scheme, verb string, obj client.Object, subresource string) (context.Context, trace.Span) {
+	kind := "object"
+	if gvk, err := apiutil.GVKForObject(obj, s); err == nil {
+		kind = gvk.Kind
+	}
+
+	name := verb + " " + kind
+	if subresource != "" {
+		name += "/" + subresource
+	}
+
+	return startSpan(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			objectKindKey.String(kind),
+			objectNameKey.String(obj.GetName()),
+			objectNamespaceKey.String(obj.GetNamespace()),
+		),
+	)
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUpserterName(t *testing.T) {
+	for _, test := range []struct {
+		f      upserter
+		expect string
+	}{
+		{Deployment, "Deployment"},
+		{ConfigMap, "ConfigMap"},
+		{RegistrySecret, "RegistrySecret"},
+	} {
+		t.Run(test.expect, func(t *testing.T) {
+			if received := upserterName(test.f); received != test.expect {
+				t.Errorf("expected %q, received %q", test.expect, received)
+			}
+		})
+	}
+}
+
+func TestReconcile_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	defer otel.SetTracerProvider(previous)
+
+	app := bot.DeepCopy()
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(app).Build()
+	r := &ClusterReconciler{Client: NewTracingClient(c), Scheme: testScheme(t)}
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: app.Name, Namespace: app.Namespace}})
+	if err != nil {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		if _, ok := spans[s.Name()]; !ok {
+			spans[s.Name()] = s
+		}
+	}
+
+	for _, test := range []struct {
+		name   string
+		parent string
+		attrs  []attribute.KeyValue
+	}{
+		{"Reconcile", "", []attribute.KeyValue{
+			clusterNameKey.String("my-test-cluster"),
+			clusterNamespaceKey.String("testing"),
+		}},
+		{"Get Cluster", "Reconcile", nil},
+		{"Reconcile gec-bot", "Reconcile", []attribute.KeyValue{
+			appKey.String("gec-bot"),
+		}},
+		{"ServiceAccount", "Reconcile gec-bot", []attribute.KeyValue{
+			clusterNameKey.String("my-test-cluster"),
+			appKey.String("gec-bot"),
+		}},
+		{"Create ServiceAccount", "ServiceAccount", []attribute.KeyValue{
+			clusterNameKey.String("my-test-cluster"),
+			appKey.String("gec-bot"),
+			objectKindKey.String("ServiceAccount"),
+			objectNameKey.String("my-test-cluster-gec-bot"),
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s, ok := spans[test.name]
+			if !ok {
+				t.Fatalf("expected span %q", test.name)
+			}
+
+			if test.parent == "" {
+				if s.Parent().IsValid() {
+					t.Errorf("expected a root span")
+				}
+			} else if p, ok := spans[test.parent]; !ok || s.Parent().SpanID() != p.SpanContext().SpanID() {
+				t.Errorf("expected %q to be a child of %q", test.name, test.parent)
+			}
+
+			received := make(map[attribute.Key]attribute.Value)
+			for _, kv := range s.Attributes() {
+				received[kv.Key] = kv.Value
+			}
+
+			for _, kv := range test.attrs {
+				if v, ok := received[kv.Key]; !ok || v != kv.Value {
+					t.Errorf("expected %s=%s, received %v", kv.Key, kv.Value.Emit(), s.Attributes())
+				}
+			}
+		})
+	}
+}
@@ -17,8 +17,12 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"net"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -28,10 +32,13 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	configv1alpha1 "github.com/gender-equality-community/gec-operator/api/config/v1alpha1"
 	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appv1beta1 "github.com/gender-equality-community/gec-operator/api/v1beta1"
 	"github.com/gender-equality-community/gec-operator/controllers"
 	//+kubebuilder:scaffold:imports
 )
@@ -45,18 +52,58 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(appv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(appv1beta1.AddToScheme(scheme))
+	utilruntime.Must(configv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		if err := render(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	var configFile string
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var watchNamespace string
+	var sharedNamespace string
+	var syncPeriod time.Duration
+	var maxConcurrentReconciles int
+	var plan bool
+	var redisPreflight bool
+	var traceExporter string
+	var traceEndpoint string
+	flag.StringVar(&configFile, "config", "",
+		"The controller will load its initial configuration from this file. "+
+			"Omit this flag to use the default configuration values. "+
+			"Command-line flags override configuration from this file.")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&watchNamespace, "watch-namespace", os.Getenv("WATCH_NAMESPACE"),
+		"Comma separated list of namespaces to watch for Clusters. "+
+			"Watches every namespace when empty, which requires cluster-wide RBAC.")
+	flag.StringVar(&sharedNamespace, "shared-namespace", os.Getenv("SHARED_NAMESPACE"),
+		"Comma separated list of namespaces, other than their own, holding Services and Secrets Clusters may reference, such as a shared redis. "+
+			"References to any other namespace are refused.")
+	flag.DurationVar(&syncPeriod, "sync-period", 10*time.Hour, "How often every Cluster is reconciled, regardless of changes.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1, "How many Clusters may be reconciled at once.")
+	flag.BoolVar(&plan, "plan", false,
+		"Plan every Cluster rather than reconciling it, writing what would change into its status without changing anything.")
+	flag.BoolVar(&redisPreflight, "redis-preflight", true,
+		"Check each Cluster's redis is reachable before reconciling it, recording an Event when it isn't.")
+	flag.StringVar(&traceExporter, "trace-exporter", "",
+		"Where to send traces of each reconcile: otlp, stdout, or none. Tracing is off by default.")
+	flag.StringVar(&traceEndpoint, "trace-endpoint", "",
+		"Base URL of the OpenTelemetry collector otlp traces are sent to. Defaults to OTEL_EXPORTER_OTLP_ENDPOINT, or http://localhost:4318.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,37 +112,151 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "dfef5bbb.gec",
-		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
-		// when the Manager ends. This requires the binary to immediately end when the
-		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
-		// speeds up voluntary leader transitions as the new leader don't have to wait
-		// LeaseDuration time first.
-		//
-		// In the default scaffold provided, the program ends immediately after
-		// the manager stops, so would be fine to enable this option. However,
-		// if you are doing or is intended to do any operation such as perform cleanups
-		// after the manager stops then its usage might be unsafe.
-		// LeaderElectionReleaseOnCancel: true,
+	var err error
+	options := ctrl.Options{Scheme: scheme}
+	operatorConfig := configv1alpha1.OperatorConfig{}
+	if configFile != "" {
+		options, err = options.AndFrom(ctrl.ConfigFile().AtPath(configFile).OfKind(&operatorConfig))
+		if err != nil {
+			setupLog.Error(err, "unable to load the config file")
+			os.Exit(1)
+		}
+	}
+
+	defaults := operatorConfig.Defaults.Apply(appv1alpha1.BuiltinDefaults())
+
+	// Flags given on the command line win over the config file, and
+	// flag defaults fill in anything the config file leaves unset
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
 	})
+
+	if set["metrics-bind-address"] || options.MetricsBindAddress == "" {
+		options.MetricsBindAddress = metricsAddr
+	}
+	if set["health-probe-bind-address"] || options.HealthProbeBindAddress == "" {
+		options.HealthProbeBindAddress = probeAddr
+	}
+	if set["leader-elect"] {
+		options.LeaderElection = enableLeaderElection
+	}
+	if set["sync-period"] || options.SyncPeriod == nil {
+		options.SyncPeriod = &syncPeriod
+	}
+	if set["max-concurrent-reconciles"] || operatorConfig.MaxConcurrentReconciles == 0 {
+		operatorConfig.MaxConcurrentReconciles = maxConcurrentReconciles
+	}
+	if set["trace-exporter"] {
+		operatorConfig.Tracing.Exporter = traceExporter
+	}
+	if set["trace-endpoint"] {
+		operatorConfig.Tracing.Endpoint = traceEndpoint
+	}
+	if options.Port == 0 {
+		options.Port = 9443
+	}
+	if options.LeaderElectionID == "" {
+		options.LeaderElectionID = "dfef5bbb.gec"
+	}
+
+	// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
+	// when the Manager ends. This requires the binary to immediately end when the
+	// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
+	// speeds up voluntary leader transitions as the new leader don't have to wait
+	// LeaseDuration time first.
+	//
+	// In the default scaffold provided, the program ends immediately after
+	// the manager stops, so would be fine to enable this option. However,
+	// if you are doing or is intended to do any operation such as perform cleanups
+	// after the manager stops then its usage might be unsafe.
+	// options.LeaderElectionReleaseOnCancel = true
+
+	namespaces := controllers.ParseNamespaces(watchNamespace)
+	if len(namespaces) > 0 {
+		setupLog.Info("watching namespaces", "namespaces", namespaces)
+
+		options = controllers.WatchNamespaces(options, namespaces)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	shutdownTracing, err := setupTracing(operatorConfig.Tracing, os.Stdout)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
+	c := mgr.GetClient()
+	if operatorConfig.Tracing.Exporter != "" {
+		setupLog.Info("tracing", "exporter", operatorConfig.Tracing.Exporter)
+
+		c = controllers.NewTracingClient(c)
+	}
+
+	var sharedCache cache.Cache
+
+	shared := controllers.ParseNamespaces(sharedNamespace)
+	if len(shared) > 0 && len(namespaces) > 0 {
+		setupLog.Info("reading shared namespaces", "namespaces", shared)
+
+		sharedCache, err = controllers.NewSharedCache(mgr, shared)
+		if err != nil {
+			setupLog.Error(err, "unable to set up the shared namespace cache")
+			os.Exit(1)
+		}
+	}
+
+	registryTokens := controllers.RegistryTokens{
+		ECR:     operatorConfig.RegistryTokens.ECR,
+		GCR:     operatorConfig.RegistryTokens.GCR,
+		Servers: operatorConfig.RegistryTokens.Servers,
+	}
+
+	var preflight func(context.Context, string) error
+	if redisPreflight {
+		preflight = controllers.DialRedis
+	}
+
 	if err = (&controllers.ClusterReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                  c,
+		Scheme:                  mgr.GetScheme(),
+		MaxConcurrentReconciles: operatorConfig.MaxConcurrentReconciles,
+		Plan:                    plan,
+		Recorder:                mgr.GetEventRecorderFor("gec-operator"),
+		RedisPreflight:          preflight,
+		SharedCache:             sharedCache,
+		SharedNamespaces:        shared,
+		LookupHost:              net.DefaultResolver.LookupHost,
+		Defaults:                &defaults,
+		RegistryTokens:          registryTokens,
+		RateLimiter: controllers.NewRateLimiter(
+			operatorConfig.RateLimit.BaseDelay.Duration,
+			operatorConfig.RateLimit.MaxDelay.Duration,
+			float64(operatorConfig.RateLimit.QPS),
+			operatorConfig.RateLimit.Burst,
+		),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		os.Exit(1)
 	}
+	// Webhooks need serving certificates, which aren't usually to hand
+	// when running locally
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&appv1beta1.Cluster{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Cluster")
+			os.Exit(1)
+		}
+
+		if err = (&controllers.ClusterValidator{Defaults: &defaults, SharedNamespaces: shared, RegistryTokens: registryTokens}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterValidator")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -112,4 +273,12 @@ func main() {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	// Send any spans still waiting to be exported
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := shutdownTracing(ctx); err != nil {
+		setupLog.Error(err, "problem flushing traces")
+	}
 }
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	configv1alpha1 "github.com/gender-equality-community/gec-operator/api/config/v1alpha1"
+	appv1alpha1 "github.com/gender-equality-community/gec-operator/api/v1alpha1"
+	appv1beta1 "github.com/gender-equality-community/gec-operator/api/v1beta1"
+	"github.com/gender-equality-community/gec-operator/controllers"
+)
+
+// render prints, as YAML, every object the operator would create for the
+// Cluster in a file. Secrets and ConfigMaps the Cluster references, such as
+// those holding credentials, can be given alongside it in the same file
+func render(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	configFile := fs.String("config", "", "Operator configuration to render with, as given to the manager's --config.")
+	namespace := fs.String("namespace", "default", "Namespace to render into, where the Cluster sets none.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s render [flags] <file|->\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+
+		return errors.New("expected a single file to render")
+	}
+
+	operatorConfig := configv1alpha1.OperatorConfig{}
+	if *configFile != "" {
+		_, err = ctrl.Options{Scheme: scheme}.AndFrom(ctrl.ConfigFile().AtPath(*configFile).OfKind(&operatorConfig))
+		if err != nil {
+			return err
+		}
+	}
+
+	in := os.Stdin
+	if fs.Arg(0) != "-" {
+		in, err = os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		defer in.Close()
+	}
+
+	app, objects, err := decodeRenderInput(in)
+	if err != nil {
+		return err
+	}
+
+	if app.Namespace == "" {
+		app.Namespace = *namespace
+	}
+
+	for _, o := range objects {
+		if o.GetNamespace() == "" {
+			o.SetNamespace(app.Namespace)
+		}
+	}
+
+	out, err := controllers.Render(context.Background(), scheme, operatorConfig.Defaults.Apply(appv1alpha1.BuiltinDefaults()), app, objects...)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range out {
+		b, err := yaml.Marshal(o)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "---\n%s", b)
+	}
+
+	return nil
+}
+
+// decodeRenderInput reads a single Cluster, in either API version, along
+// with any other objects, from a stream of YAML documents
+func decodeRenderInput(r io.Reader) (app *appv1alpha1.Cluster, objects []client.Object, err error) {
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	docs := yamlutil.NewYAMLReader(bufio.NewReader(r))
+
+	for {
+		doc, err := docs.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		obj, _, err := decoder.Decode(doc, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var cluster *appv1alpha1.Cluster
+
+		switch o := obj.(type) {
+		case *appv1alpha1.Cluster:
+			cluster = o
+
+		case *appv1beta1.Cluster:
+			cluster = new(appv1alpha1.Cluster)
+
+			err = o.ConvertTo(cluster)
+			if err != nil {
+				return nil, nil, err
+			}
+
+		case client.Object:
+			objects = append(objects, o)
+
+			continue
+
+		default:
+			return nil, nil, fmt.Errorf("can't render with a %T", obj)
+		}
+
+		if app != nil {
+			return nil, nil, errors.New("expected a single Cluster, found more")
+		}
+
+		app = cluster
+	}
+
+	if app == nil {
+		return nil, nil, errors.New("expected a Cluster, found none")
+	}
+
+	return app, objects, nil
+}
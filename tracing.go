@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	configv1alpha1 "github.com/gender-equality-community/gec-operator/api/config/v1alpha1"
+)
+
+// defaultOTLPEndpoint is where OTLP/HTTP collectors listen by default
+const defaultOTLPEndpoint = "http://localhost:4318"
+
+// setupTracing installs a TracerProvider sending spans to the configured
+// exporter. The returned func flushes any spans not yet sent. Without an
+// exporter, tracing stays off
+func setupTracing(t configv1alpha1.Tracing, stdout io.Writer) (shutdown func(context.Context) error, err error) {
+	var exporter sdktrace.SpanExporter
+
+	switch t.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(stdout))
+		if err != nil {
+			return
+		}
+
+	case "otlp":
+		exporter, err = otlpExporter(t.Endpoint)
+		if err != nil {
+			return
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, expected otlp or stdout", t.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", "gec-operator")))
+	if err != nil {
+		return
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// otlpExporter sends spans to a collector's base URL over OTLP/HTTP.
+// Without one, the exporter reads the standard OTEL_EXPORTER_OTLP_*
+// variables, and failing those sends to a collector on localhost
+func otlpExporter(endpoint string) (sdktrace.SpanExporter, error) {
+	if endpoint == "" {
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			return otlptracehttp.New(context.Background())
+		}
+
+		endpoint = defaultOTLPEndpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace endpoint %q: %w", endpoint, err)
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + "/v1/traces"),
+	}
+
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	return otlptracehttp.New(context.Background(), opts...)
+}
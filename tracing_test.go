@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	configv1alpha1 "github.com/gender-equality-community/gec-operator/api/config/v1alpha1"
+)
+
+func TestSetupTracing(t *testing.T) {
+	for _, test := range []struct {
+		exporter    string
+		expectError bool
+	}{
+		{"", false},
+		{"none", false},
+		{"stdout", false},
+		{"otlp", false},
+		{"jaeger", true},
+	} {
+		t.Run(test.exporter, func(t *testing.T) {
+			shutdown, err := setupTracing(configv1alpha1.Tracing{Exporter: test.exporter}, io.Discard)
+			if err == nil && test.expectError {
+				t.Fatalf("expected error")
+			} else if err != nil && !test.expectError {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if shutdown != nil {
+				err = shutdown(context.Background())
+				if err != nil {
+					t.Errorf("unexpected error: %#v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSetupTracing_OTLP(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		endpoint func(srv *httptest.Server) string
+		env      func(srv *httptest.Server) map[string]string
+	}{
+		{"configured", func(srv *httptest.Server) string { return srv.URL + "/" }, nil},
+		{"from env", func(*httptest.Server) string { return "" }, func(srv *httptest.Server) map[string]string {
+			return map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": srv.URL}
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var received []string
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil || r.URL.Path != "/v1/traces" {
+					w.WriteHeader(http.StatusBadRequest)
+
+					return
+				}
+
+				req := new(coltracepb.ExportTraceServiceRequest)
+
+				err = proto.Unmarshal(body, req)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+
+					return
+				}
+
+				for _, rs := range req.ResourceSpans {
+					for _, ss := range rs.ScopeSpans {
+						for _, s := range ss.Spans {
+							received = append(received, s.Name)
+						}
+					}
+				}
+			}))
+			defer srv.Close()
+
+			t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+			t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+			if test.env != nil {
+				for k, v := range test.env(srv) {
+					t.Setenv(k, v)
+				}
+			}
+
+			shutdown, err := setupTracing(configv1alpha1.Tracing{Exporter: "otlp", Endpoint: test.endpoint(srv)}, io.Discard)
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			_, span := otel.Tracer("test").Start(context.Background(), "Reconcile")
+			span.End()
+
+			err = shutdown(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %#v", err)
+			}
+
+			if len(received) != 1 || received[0] != "Reconcile" {
+				t.Errorf("expected the Reconcile span, received %v", received)
+			}
+		})
+	}
+}